@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// serverSettings records the startup settings that are otherwise local to
+// main, so /config can report them
+var serverSettings struct {
+	listenAddrs         []string
+	commandPath         string
+	tls                 bool
+	requestTimeout      time.Duration
+	httpTimeouts        map[string]string
+	shutdownGracePeriod time.Duration
+	maxConcurrent       int
+	redisTarget         string
+	grpcPort            string
+}
+
+// EffectiveConfig is the resolved, non-secret configuration served by /config.
+// Secrets are reported only as whether they are set.
+type EffectiveConfig struct {
+	ListenAddrs             []string            `json:"listen_addrs"`
+	CommandPath             string              `json:"command_path"`
+	TLS                     bool                `json:"tls"`
+	LogLevel                string              `json:"log_level"`
+	Backends                []string            `json:"backends"`
+	DryRun                  bool                `json:"dry_run"`
+	RedisTarget             string              `json:"redis_target,omitempty"`
+	RedisChannel            string              `json:"redis_channel"`
+	RedisChannelPrefix      string              `json:"redis_channel_prefix"`
+	InteractiveChannel      string              `json:"interactive_channel"`
+	ShortcutChannel         string              `json:"shortcut_channel"`
+	CommandChannels         map[string]string   `json:"command_channels,omitempty"`
+	RedisMode               string              `json:"redis_mode"`
+	Compression             string              `json:"compression"`
+	PartitionKey            string              `json:"partition_key"`
+	PayloadCase             string              `json:"payload_case"`
+	TimestampFormat         string              `json:"timestamp_format"`
+	WebhookURL              string              `json:"webhook_url,omitempty"`
+	RequestTimeout          string              `json:"request_timeout"`
+	HTTPTimeouts            map[string]string   `json:"http_timeouts"`
+	ShutdownGracePeriod     string              `json:"shutdown_grace_period"`
+	DrainTimeout            string              `json:"drain_timeout"`
+	RedisPublishTimeout     string              `json:"redis_publish_timeout"`
+	MaxBodyBytes            int64               `json:"max_body_bytes"`
+	MaxConcurrent           int                 `json:"max_concurrent"`
+	PublishWorkers          bool                `json:"async_publishing"`
+	RetryQueue              bool                `json:"retry_queue"`
+	TeamAllowlist           []string            `json:"team_allowlist,omitempty"`
+	CommandAllowlist        []string            `json:"command_allowlist,omitempty"`
+	CommandDenylist         []string            `json:"command_denylist,omitempty"`
+	ChannelAllowlist        []string            `json:"channel_allowlist,omitempty"`
+	CommandChannelAllowlist map[string][]string `json:"command_channel_allowlist,omitempty"`
+	RedactFields            []string            `json:"redact_fields"`
+	SigningSecrets          int                 `json:"signing_secrets"`
+	SecretsMapEntries       int                 `json:"secrets_map_entries"`
+	VerificationToken       bool                `json:"verification_token_set"`
+	TrustedIPs              []string            `json:"trusted_ips,omitempty"`
+	ChannelOverride         bool                `json:"channel_override"`
+	Dedupe                  bool                `json:"dedupe"`
+	GRPCPort                string              `json:"grpc_port,omitempty"`
+	SocketMode              bool                `json:"socket_mode"`
+	NormalizeCommand        []string            `json:"normalize_command,omitempty"`
+}
+
+// effectiveConfig snapshots the configuration the process resolved at startup
+func effectiveConfig() EffectiveConfig {
+	cfg := EffectiveConfig{
+		ListenAddrs:         serverSettings.listenAddrs,
+		CommandPath:         serverSettings.commandPath,
+		TLS:                 serverSettings.tls,
+		LogLevel:            currentLogLevel.String(),
+		Backends:            backends,
+		DryRun:              dryRun,
+		RedisChannel:        redisChannel,
+		RedisChannelPrefix:  channelPrefix,
+		InteractiveChannel:  interactiveChannel,
+		ShortcutChannel:     shortcutChannel,
+		CommandChannels:     commandChannels,
+		RedisMode:           redisMode,
+		Compression:         payloadCompression,
+		PartitionKey:        partitionKey,
+		PayloadCase:         payloadCase,
+		TimestampFormat:     timestampFormat,
+		WebhookURL:          redactURL(webhookURL),
+		RequestTimeout:      serverSettings.requestTimeout.String(),
+		HTTPTimeouts:        serverSettings.httpTimeouts,
+		ShutdownGracePeriod: serverSettings.shutdownGracePeriod.String(),
+		DrainTimeout:        drainTimeout.String(),
+		RedisPublishTimeout: redisPublishTimeout.String(),
+		MaxBodyBytes:        maxBodyBytes,
+		MaxConcurrent:       serverSettings.maxConcurrent,
+		PublishWorkers:      publishQueue != nil,
+		RetryQueue:          publishRetryQueue != nil,
+		TeamAllowlist:       sortedKeys(teamAllowlist),
+		CommandAllowlist:    sortedKeys(commandAllowlist),
+		CommandDenylist:     sortedKeys(commandDenylist),
+		ChannelAllowlist:    sortedKeys(channelAllowlist),
+		RedactFields:        sortedKeys(redactFields),
+		SigningSecrets:      len(getSigningSecrets()),
+		SecretsMapEntries:   len(secretsMap),
+		VerificationToken:   len(verificationToken) > 0,
+		ChannelOverride:     allowChannelOverride,
+		Dedupe:              dedupe,
+		SocketMode:          socketMode,
+		NormalizeCommand:    sortedKeys(commandNormalization),
+	}
+	if hasBackend(backendRedis) {
+		cfg.RedisTarget = serverSettings.redisTarget
+	}
+	for command, channels := range commandChannelAllowlists {
+		if cfg.CommandChannelAllowlist == nil {
+			cfg.CommandChannelAllowlist = make(map[string][]string)
+		}
+		cfg.CommandChannelAllowlist[command] = sortedKeys(channels)
+	}
+	if enableGRPC {
+		cfg.GRPCPort = serverSettings.grpcPort
+	}
+	for _, network := range trustedNetworks {
+		cfg.TrustedIPs = append(cfg.TrustedIPs, network.String())
+	}
+	return cfg
+}
+
+// redactURL strips credentials and the query string, which often carries a
+// token, from a URL
+func redactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || value == "" {
+		return ""
+	}
+	u.User = nil
+	if u.RawQuery != "" {
+		u.RawQuery = redactedValue
+	}
+	return u.String()
+}
+
+// sortedKeys returns the members of a set in a stable order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// configHandler serves the effective configuration to operators holding the
+// admin token
+func configHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !verifyAdminToken(r) {
+		logWarn("Rejected /config request with an invalid admin token from %s", r.RemoteAddr)
+		http.Error(w, "Invalid admin token", http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(effectiveConfig())
+}
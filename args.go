@@ -0,0 +1,58 @@
+package main
+
+import "unicode"
+
+// ParseArgs splits command text into arguments using shell-like rules:
+// whitespace separates arguments, single quotes preserve their contents literally,
+// double quotes group words while allowing \" and \\ escapes, and a backslash
+// outside quotes escapes the next character. An unterminated quote runs to the
+// end of the text. Empty text yields an empty, non-nil slice.
+func ParseArgs(text string) []string {
+	args := []string{}
+	var current []rune
+	inArg := false
+	var quote rune
+	escaped := false
+
+	for _, r := range text {
+		switch {
+		case escaped:
+			current = append(current, r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				current = append(current, r)
+			}
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else if r == '\\' {
+				escaped = true
+			} else {
+				current = append(current, r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == '\\':
+			escaped = true
+			inArg = true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, string(current))
+				current = current[:0]
+				inArg = false
+			}
+		default:
+			current = append(current, r)
+			inArg = true
+		}
+	}
+
+	if inArg {
+		args = append(args, string(current))
+	}
+	return args
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{"empty", "", []string{}},
+		{"whitespace only", "   \t ", []string{}},
+		{"single word", "deploy", []string{"deploy"}},
+		{"multiple words", "deploy api  prod", []string{"deploy", "api", "prod"}},
+		{"double quotes", `say "hello world"`, []string{"say", "hello world"}},
+		{"single quotes", `say 'hello world'`, []string{"say", "hello world"}},
+		{"single quotes are literal", `'a\b "c"'`, []string{`a\b "c"`}},
+		{"escaped quote in double quotes", `"say \"hi\""`, []string{`say "hi"`}},
+		{"escaped space", `hello\ world again`, []string{"hello world", "again"}},
+		{"adjacent quoted parts", `--name="my app"`, []string{"--name=my app"}},
+		{"empty quoted arg", `a "" b`, []string{"a", "", "b"}},
+		{"unterminated quote", `say "hello world`, []string{"say", "hello world"}},
+		{"unicode", "déployer 🚀", []string{"déployer", "🚀"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseArgs(tt.input)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("ParseArgs(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
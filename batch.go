@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// defaultBatchInterval is how long a partial batch waits for more commands when
+// BATCH_INTERVAL is unset
+const defaultBatchInterval = 10 * time.Millisecond
+
+// batchSize and batchInterval configure BATCH_SIZE and BATCH_INTERVAL. Batching
+// is enabled when batchSize is greater than 1.
+var batchSize int
+var batchInterval = defaultBatchInterval
+
+// batchItem is a command waiting in a batch; done receives its publish result
+type batchItem struct {
+	channel string
+	key     string
+	command SlackCommand
+	payload []byte
+	done    chan error
+}
+
+// batchingRedisPublisher publishes to Redis like redisPublisher, but collects
+// commands for up to interval or size commands and writes each batch with a
+// single pipelined round trip. Publish still waits for its own command's result,
+// so failures are retried as usual; the cost is up to interval extra latency.
+type batchingRedisPublisher struct {
+	size     int
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending []batchItem
+	timer   *time.Timer
+	closed  bool
+}
+
+func newBatchingRedisPublisher(size int, interval time.Duration) *batchingRedisPublisher {
+	return &batchingRedisPublisher{size: size, interval: interval}
+}
+
+func (b *batchingRedisPublisher) Publish(ctx context.Context, key string, payload []byte) error {
+	if !redisConnected.Load() {
+		return errRedisUnavailable
+	}
+	command := publishMetaFrom(ctx).command
+	channel := redisChannelFor(command, channelOverrideFrom(ctx))
+	item := batchItem{channel: channel, key: key, command: command, payload: payload, done: make(chan error, 1)}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		flushBatch([]batchItem{item})
+	} else {
+		b.pending = append(b.pending, item)
+		switch {
+		case len(b.pending) >= b.size:
+			batch := b.takePending()
+			b.mu.Unlock()
+			flushBatch(batch)
+		case len(b.pending) == 1:
+			b.timer = time.AfterFunc(b.interval, b.flushPending)
+			b.mu.Unlock()
+		default:
+			b.mu.Unlock()
+		}
+	}
+
+	// The batch is written with its own timeout, so wait for the result even if
+	// ctx ends first: reporting a failure for a command that was published
+	// would have it retried and delivered twice
+	if err := <-item.done; err != nil {
+		return fmt.Errorf("redis %s '%s': %w", redisMode, channel, err)
+	}
+	logDebug("Published command to Redis %s: %s", redisMode, channel)
+	return nil
+}
+
+// takePending removes and returns the current batch; b.mu must be held
+func (b *batchingRedisPublisher) takePending() []batchItem {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	batch := b.pending
+	b.pending = nil
+	return batch
+}
+
+// flushPending publishes the partial batch when the interval elapses
+func (b *batchingRedisPublisher) flushPending() {
+	b.mu.Lock()
+	batch := b.takePending()
+	b.mu.Unlock()
+	flushBatch(batch)
+}
+
+// Close flushes any partial batch, then closes the Redis client
+func (b *batchingRedisPublisher) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	batch := b.takePending()
+	b.mu.Unlock()
+	flushBatch(batch)
+	return redisPublisher{}.Close()
+}
+
+// flushBatch writes a batch in one pipeline and reports each command's result.
+// Only a failed publish is an error; failed REDIS_METRICS counters are logged.
+func flushBatch(batch []batchItem) {
+	if len(batch) == 0 {
+		return
+	}
+	client := getRedisClient()
+	if client == nil {
+		for _, item := range batch {
+			item.done <- errRedisUnavailable
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisPublishTimeout)
+	defer cancel()
+	results := make([]error, len(batch))
+	publishes := make([]redis.Cmder, len(batch))
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, item := range batch {
+			payload, err := encodeRedisPayload(item.payload)
+			if err != nil {
+				results[i] = err
+				continue
+			}
+			publishes[i] = queueRedisPublish(ctx, pipe, item.channel, item.key, item.command, payload)
+			if redisMetrics {
+				for _, key := range commandMetricKeys(item.command.Command, time.Now()) {
+					pipe.Incr(ctx, key)
+				}
+			}
+		}
+		return nil
+	})
+	failed := false
+	for i, item := range batch {
+		if publishes[i] != nil {
+			results[i] = pipelinedErr(publishes[i], err)
+		}
+		failed = failed || results[i] != nil
+		item.done <- results[i]
+	}
+	if err != nil && !failed {
+		logWarn("Error updating Redis metrics for a batch of %d commands: %v", len(batch), err)
+	}
+	logDebug("Flushed a batch of %d commands to Redis", len(batch))
+}
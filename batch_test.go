@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeRedis is a minimal RESP server that answers every command with :1 after
+// latency, simulating the network round trip a pipeline saves
+type fakeRedis struct {
+	listener net.Listener
+	latency  time.Duration
+	commands atomic.Int64
+	// roundTrips counts the times replies were written back
+	roundTrips atomic.Int64
+}
+
+func newFakeRedis(tb testing.TB, latency time.Duration) *fakeRedis {
+	tb.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatalf("listen: %v", err)
+	}
+	f := &fakeRedis{listener: listener, latency: latency}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	tb.Cleanup(func() { listener.Close() })
+	return f
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+	replied := false
+	for {
+		name, err := readRESPCommand(reader)
+		if err != nil {
+			return
+		}
+		if strings.EqualFold(name, "hello") {
+			// Like Redis 5, so the client falls back to RESP2
+			writer.WriteString("-ERR unknown command 'HELLO'\r\n")
+		} else {
+			f.commands.Add(1)
+			writer.WriteString(":1\r\n")
+			replied = true
+		}
+		// Reply once the client has nothing more queued, like a real round trip
+		if reader.Buffered() == 0 {
+			if replied {
+				time.Sleep(f.latency)
+				f.roundTrips.Add(1)
+				replied = false
+			}
+			if writer.Flush() != nil {
+				return
+			}
+		}
+	}
+}
+
+// readRESPCommand reads one command, an array of bulk strings, and returns its name
+func readRESPCommand(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	var name string
+	for i := 0; i < n; i++ {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(header, "$")))
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, arg); err != nil {
+			return "", err
+		}
+		if i == 0 {
+			name = string(arg[:size])
+		}
+	}
+	return name, nil
+}
+
+// useFakeRedis points the Redis client at f
+func useFakeRedis(tb testing.TB, f *fakeRedis) {
+	tb.Helper()
+	client := redis.NewClient(&redis.Options{Addr: f.listener.Addr().String(), DisableIdentity: true, PoolSize: 64})
+	tb.Cleanup(func() { client.Close() })
+	setRedisClient(client)
+	redisConnected.Store(true)
+}
+
+func TestBatchingRedisPublisher_FlushesFullBatch(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	f := newFakeRedis(t, 0)
+	useFakeRedis(t, f)
+
+	// A long interval means only a full batch can trigger the flush
+	publisher := newBatchingRedisPublisher(4, time.Hour)
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Go(func() {
+			ctx := withPublishMeta(context.Background(), SlackCommand{Command: "/deploy"}, "")
+			if err := publisher.Publish(ctx, "T1", []byte("{}")); err != nil {
+				t.Errorf("unexpected publish error: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+	if got := f.commands.Load(); got != 4 {
+		t.Errorf("expected 4 commands, got %d", got)
+	}
+	if got := f.roundTrips.Load(); got != 1 {
+		t.Errorf("expected the batch in 1 round trip, got %d", got)
+	}
+}
+
+func TestBatchingRedisPublisher_FlushesAfterInterval(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	f := newFakeRedis(t, 0)
+	useFakeRedis(t, f)
+
+	publisher := newBatchingRedisPublisher(100, 10*time.Millisecond)
+	ctx := withPublishMeta(context.Background(), SlackCommand{Command: "/deploy"}, "")
+	if err := publisher.Publish(ctx, "T1", []byte("{}")); err != nil {
+		t.Fatalf("unexpected publish error: %v", err)
+	}
+	if got := f.commands.Load(); got != 1 {
+		t.Errorf("expected the partial batch to be flushed, got %d commands", got)
+	}
+}
+
+func TestBatchingRedisPublisher_CloseFlushesPartialBatch(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	f := newFakeRedis(t, 0)
+	useFakeRedis(t, f)
+
+	publisher := newBatchingRedisPublisher(100, time.Hour)
+	result := make(chan error, 1)
+	go func() {
+		ctx := withPublishMeta(context.Background(), SlackCommand{Command: "/deploy"}, "")
+		result <- publisher.Publish(ctx, "T1", []byte("{}"))
+	}()
+	// Wait for the command to join the batch
+	for {
+		publisher.mu.Lock()
+		n := len(publisher.pending)
+		publisher.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	publisher.Close()
+	if err := <-result; err != nil {
+		t.Errorf("expected the partial batch to be published at close, got %v", err)
+	}
+	if got := f.commands.Load(); got != 1 {
+		t.Errorf("expected 1 command, got %d", got)
+	}
+}
+
+func TestBatchingRedisPublisher_Unreachable(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	// Nothing listens on port 1, so the publish fails fast
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer client.Close()
+	setRedisClient(client)
+	redisConnected.Store(true)
+
+	publisher := newBatchingRedisPublisher(2, time.Millisecond)
+	ctx := withPublishMeta(context.Background(), SlackCommand{Command: "/deploy"}, "")
+	if err := publisher.Publish(ctx, "T1", []byte("{}")); err == nil {
+		t.Error("expected an error publishing to an unreachable Redis")
+	}
+}
+
+// benchmarkRedisPublish publishes from 64 goroutines to a Redis with 200µs of
+// simulated network latency
+func benchmarkRedisPublish(b *testing.B, publisher Publisher) {
+	saveAndRestoreGlobals(b)
+	useFakeRedis(b, newFakeRedis(b, 200*time.Microsecond))
+	ctx := withPublishMeta(context.Background(), SlackCommand{Command: "/deploy"}, "")
+	payload := []byte(`{"command":{"command":"/deploy","text":"api production"}}`)
+
+	b.SetParallelism(max(1, 64/runtime.GOMAXPROCS(0)))
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := publisher.Publish(ctx, "T1", payload); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+}
+
+func BenchmarkRedisPublish_Unbatched(b *testing.B) {
+	benchmarkRedisPublish(b, redisPublisher{})
+}
+
+func BenchmarkRedisPublish_Batched(b *testing.B) {
+	benchmarkRedisPublish(b, newBatchingRedisPublisher(64, time.Millisecond))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// defaultChannelNotAllowedMessage is shown for commands used outside their allowed channels
+const defaultChannelNotAllowedMessage = "This command is not available in this channel."
+
+// channelAllowlist holds the CHANNEL_ALLOWLIST channel IDs commands may be
+// used in; nil allows every channel
+var channelAllowlist map[string]bool
+
+// commandChannelAllowlists holds per-command channel allowlists from
+// COMMAND_CHANNEL_ALLOWLIST. A command listed here is governed by its own
+// channels instead of CHANNEL_ALLOWLIST.
+var commandChannelAllowlists map[string]map[string]bool
+
+// parseCommandChannelAllowlists parses COMMAND_CHANNEL_ALLOWLIST, given either
+// as a JSON object of channel ID arrays such as {"/deploy":["C1","C2"]} or as
+// comma-separated command:channel pairs, repeating a command for each channel
+func parseCommandChannelAllowlists(value string) (map[string]map[string]bool, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	allowlists := make(map[string]map[string]bool)
+	if strings.HasPrefix(value, "{") {
+		var raw map[string][]string
+		if err := json.Unmarshal([]byte(value), &raw); err != nil {
+			return nil, fmt.Errorf("parsing JSON: %w", err)
+		}
+		for command, channels := range raw {
+			allowlists[command] = toSet(channels)
+			if allowlists[command] == nil {
+				return nil, fmt.Errorf("no channels for %s", command)
+			}
+		}
+		return allowlists, nil
+	}
+
+	for _, pair := range splitList(value) {
+		command, channel, ok := strings.Cut(pair, ":")
+		command = strings.TrimSpace(command)
+		channel = strings.TrimSpace(channel)
+		if !ok || command == "" || channel == "" {
+			return nil, fmt.Errorf("invalid entry %q, expected command:channel_id", pair)
+		}
+		if allowlists[command] == nil {
+			allowlists[command] = make(map[string]bool)
+		}
+		allowlists[command][channel] = true
+	}
+	return allowlists, nil
+}
+
+// channelAllowed reports whether a command may be used in its channel, under
+// its own allowlist if it has one and CHANNEL_ALLOWLIST otherwise
+func channelAllowed(command SlackCommand) bool {
+	if allowed, ok := commandChannelAllowlists[command.Command]; ok {
+		return allowed[command.ChannelID]
+	}
+	return channelAllowlist == nil || channelAllowlist[command.ChannelID]
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCommandChannelAllowlists(t *testing.T) {
+	want := map[string]map[string]bool{
+		"/deploy": {"C1": true, "C2": true},
+		"/status": {"C3": true},
+	}
+	for _, value := range []string{
+		"/deploy:C1, /deploy:C2, /status:C3",
+		`{"/deploy":["C1","C2"],"/status":["C3"]}`,
+	} {
+		got, err := parseCommandChannelAllowlists(value)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expected %v, got %v, %v", value, want, got, err)
+		}
+	}
+
+	if got, err := parseCommandChannelAllowlists(""); got != nil || err != nil {
+		t.Errorf("expected no allowlists, got %v, %v", got, err)
+	}
+	for _, value := range []string{"/deploy", "/deploy:", `{"/deploy":"C1"}`, `{"/deploy":[]}`} {
+		if _, err := parseCommandChannelAllowlists(value); err == nil {
+			t.Errorf("%s: expected an error", value)
+		}
+	}
+}
+
+func TestChannelAllowed(t *testing.T) {
+	origGlobal, origPerCommand := channelAllowlist, commandChannelAllowlists
+	t.Cleanup(func() { channelAllowlist, commandChannelAllowlists = origGlobal, origPerCommand })
+
+	channelAllowlist, commandChannelAllowlists = nil, nil
+	if !channelAllowed(SlackCommand{Command: "/deploy", ChannelID: "C9"}) {
+		t.Error("expected every channel to be allowed without allowlists")
+	}
+
+	channelAllowlist = toSet([]string{"C1"})
+	commandChannelAllowlists, _ = parseCommandChannelAllowlists("/deploy:C2")
+	tests := []struct {
+		command, channel string
+		want             bool
+	}{
+		{"/status", "C1", true},
+		{"/status", "C2", false},
+		// A per-command allowlist replaces CHANNEL_ALLOWLIST for that command
+		{"/deploy", "C2", true},
+		{"/deploy", "C1", false},
+	}
+	for _, tt := range tests {
+		if got := channelAllowed(SlackCommand{Command: tt.command, ChannelID: tt.channel}); got != tt.want {
+			t.Errorf("%s in %s: expected %t, got %t", tt.command, tt.channel, tt.want, got)
+		}
+	}
+}
+
+func TestSlackCommandHandler_ChannelAllowlist(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	setSigningSecrets(nil)
+	publishQueue = nil
+	fake := &fakePublisher{}
+	activePublisher = fake
+	origGlobal, origPerCommand := channelAllowlist, commandChannelAllowlists
+	t.Cleanup(func() { channelAllowlist, commandChannelAllowlists = origGlobal, origPerCommand })
+	channelAllowlist = toSet([]string{"C1"})
+	commandChannelAllowlists = nil
+
+	w := httptest.NewRecorder()
+	slackCommandHandler(w, httptest.NewRequest(http.MethodPost, "/command", strings.NewReader("command=%2Fdeploy&team_id=T1&channel_id=C2")))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 so Slack shows the message, got %d", w.Code)
+	}
+	var response SlackResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.ResponseType != responseTypeEphemeral || response.Text != defaultChannelNotAllowedMessage {
+		t.Errorf("unexpected response %+v", response)
+	}
+	if len(fake.payloads) != 0 {
+		t.Errorf("expected nothing published, got %d", len(fake.payloads))
+	}
+
+	w = httptest.NewRecorder()
+	slackCommandHandler(w, httptest.NewRequest(http.MethodPost, "/command", strings.NewReader("command=%2Fdeploy&team_id=T1&channel_id=C1")))
+	if w.Code != http.StatusOK || len(fake.payloads) != 1 {
+		t.Errorf("expected the allowed channel to publish, got %d and %d publishes", w.Code, len(fake.payloads))
+	}
+}
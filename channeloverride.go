@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+// channelOverrideHeader lets an internal caller pick the Redis channel for a
+// single request. Slack never sends it.
+const channelOverrideHeader = "X-Relay-Channel"
+
+// allowChannelOverride enables channelOverrideHeader. It is off by default since
+// it lets any caller with a valid signature choose where a command is published.
+var allowChannelOverride bool
+
+// channelOverridePattern is the allowlist an overriding channel must fully match
+var channelOverridePattern *regexp.Regexp
+
+// channelPrefix is REDIS_CHANNEL_PREFIX, prepended to overriding channels too
+var channelPrefix string
+
+// parseChannelOverridePattern compiles CHANNEL_OVERRIDE_PATTERN, anchored so the
+// whole channel name has to match
+func parseChannelOverridePattern(value string) (*regexp.Regexp, error) {
+	if value == "" {
+		return nil, errors.New("must be set when ALLOW_CHANNEL_OVERRIDE is enabled")
+	}
+	pattern, err := regexp.Compile("^(?:" + value + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	return pattern, nil
+}
+
+// requestChannelOverride returns the prefixed channel named by the request's
+// channelOverrideHeader, or "" when there is none or overrides are disabled.
+// ok is false when the header names a channel outside channelOverridePattern.
+func requestChannelOverride(r *http.Request) (channel string, ok bool) {
+	value := r.Header.Get(channelOverrideHeader)
+	if value == "" || !allowChannelOverride {
+		return "", true
+	}
+	if channelOverridePattern == nil || !channelOverridePattern.MatchString(value) {
+		return "", false
+	}
+	return channelPrefix + value, true
+}
+
+type channelOverrideKey struct{}
+
+// withChannelOverride returns a context that routes Redis publishes to channel
+// instead of the command's configured channel. An empty channel changes nothing.
+func withChannelOverride(ctx context.Context, channel string) context.Context {
+	if channel == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, channelOverrideKey{}, channel)
+}
+
+// channelOverrideFrom returns the channel stored by withChannelOverride, if any
+func channelOverrideFrom(ctx context.Context) string {
+	channel, _ := ctx.Value(channelOverrideKey{}).(string)
+	return channel
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseChannelOverridePattern(t *testing.T) {
+	if _, err := parseChannelOverridePattern(""); err == nil {
+		t.Error("expected an error for an empty pattern")
+	}
+	if _, err := parseChannelOverridePattern("test-("); err == nil {
+		t.Error("expected an error for an invalid pattern")
+	}
+
+	pattern, err := parseChannelOverridePattern("test-[a-z]+")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !pattern.MatchString("test-deploy") {
+		t.Error("expected test-deploy to match")
+	}
+	if pattern.MatchString("prod-test-deploy") || pattern.MatchString("test-deploy-2") {
+		t.Error("expected the pattern to be anchored")
+	}
+}
+
+func TestSlackCommandHandler_ChannelOverride(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	origAllow, origPattern, origPrefix := allowChannelOverride, channelOverridePattern, channelPrefix
+	t.Cleanup(func() {
+		allowChannelOverride, channelOverridePattern, channelPrefix = origAllow, origPattern, origPrefix
+	})
+	setSigningSecrets(nil)
+	publishQueue = nil
+	channelOverridePattern, _ = parseChannelOverridePattern("test-[a-z]+")
+	channelPrefix = "staging:"
+
+	tests := []struct {
+		name     string
+		allow    bool
+		header   string
+		status   int
+		expected string
+	}{
+		{"no header", true, "", http.StatusOK, ""},
+		{"allowed", true, "test-deploy", http.StatusOK, "staging:test-deploy"},
+		{"not matching", true, "prod-deploy", http.StatusForbidden, ""},
+		{"disabled", false, "test-deploy", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowChannelOverride = tt.allow
+			fake := &fakePublisher{}
+			activePublisher = fake
+
+			req := httptest.NewRequest(http.MethodPost, "/command", strings.NewReader("command=%2Fdeploy&team_id=T1"))
+			if tt.header != "" {
+				req.Header.Set(channelOverrideHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			slackCommandHandler(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("expected %d, got %d", tt.status, w.Code)
+			}
+			if tt.status != http.StatusOK {
+				if len(fake.channels) != 0 {
+					t.Errorf("expected nothing to be published, got %d publishes", len(fake.channels))
+				}
+				return
+			}
+			if len(fake.channels) != 1 || fake.channels[0] != tt.expected {
+				t.Errorf("expected channel override %q, got %q", tt.expected, fake.channels)
+			}
+		})
+	}
+}
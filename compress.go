@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+)
+
+const (
+	compressionNone = "none"
+	compressionGzip = "gzip"
+)
+
+// payloadCompression is how payloads are encoded before publishing to Redis.
+// Gzip output always starts with the magic bytes 0x1f 0x8b, which can never
+// start a JSON document, so consumers can tell compressed payloads apart.
+var payloadCompression = compressionNone
+
+// parseCompression converts COMPRESS_PAYLOAD to a compression mode, defaulting to none
+func parseCompression(value string) string {
+	switch strings.ToLower(value) {
+	case "", compressionNone:
+		return compressionNone
+	case compressionGzip:
+		return compressionGzip
+	default:
+		logWarn("Unknown COMPRESS_PAYLOAD %q, using %s", value, compressionNone)
+		return compressionNone
+	}
+}
+
+// gzipPayload compresses a payload with gzip
+func gzipPayload(payload []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// isGzipped reports whether a payload starts with the gzip magic bytes
+func isGzipped(payload []byte) bool {
+	return len(payload) >= 2 && payload[0] == 0x1f && payload[1] == 0x8b
+}
+
+// decodePayload reverses gzipPayload, returning uncompressed payloads unchanged.
+// It documents, and tests, what consumers are expected to do.
+func decodePayload(payload []byte) ([]byte, error) {
+	if !isGzipped(payload) {
+		return payload, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
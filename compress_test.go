@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGzipPayload_RoundTrip(t *testing.T) {
+	envelope := newPublishEnvelope(SlackCommand{Command: "/deploy", Text: strings.Repeat("large text ", 1000)}, "req-1", time.Now())
+	payload, err := json.Marshal(envelope)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compressed, err := gzipPayload(payload)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !isGzipped(compressed) {
+		t.Error("expected compressed payload to start with the gzip magic bytes")
+	}
+	if len(compressed) >= len(payload) {
+		t.Errorf("expected compression to shrink the payload, got %d >= %d bytes", len(compressed), len(payload))
+	}
+
+	decoded, err := decodePayload(compressed)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(decoded, payload) {
+		t.Error("expected round trip to reproduce the original payload")
+	}
+}
+
+func TestDecodePayload_Uncompressed(t *testing.T) {
+	payload := []byte(`{"version":1}`)
+	if isGzipped(payload) {
+		t.Error("expected JSON not to be detected as gzip")
+	}
+	decoded, err := decodePayload(payload)
+	if err != nil || !bytes.Equal(decoded, payload) {
+		t.Errorf("expected uncompressed payload unchanged, got %q, %v", decoded, err)
+	}
+}
+
+func TestParseCompression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"", compressionNone},
+		{"none", compressionNone},
+		{"gzip", compressionGzip},
+		{"GZIP", compressionGzip},
+		{"zstd", compressionNone},
+	}
+	for _, tt := range tests {
+		if got := parseCompression(tt.input); got != tt.expected {
+			t.Errorf("parseCompression(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
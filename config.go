@@ -0,0 +1,353 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the core settings, populated once at startup from the
+// environment after any CONFIG_FILE values have been merged in
+type Config struct {
+	Port         string
+	LogLevel     string
+	LogFormat    string
+	RedisChannel string
+	HTTPPath     string
+	SecretFile   string
+}
+
+// loadConfig reads the core settings from the environment, applying defaults
+func loadConfig() Config {
+	return Config{
+		Port:         getEnvString("PORT", "8080"),
+		LogLevel:     getEnvString("LOG_LEVEL", "INFO"),
+		LogFormat:    getEnvString("LOG_FORMAT", logFormatText),
+		RedisChannel: getEnvString("REDIS_CHANNEL", "slack-commands"),
+		HTTPPath:     getEnvString("HTTP_PATH", "/command"),
+		SecretFile:   getEnvString("SECRET_FILE", secretFile),
+	}
+}
+
+// getEnvString returns the named environment variable, or defaultValue when it is unset or empty
+func getEnvString(name, defaultValue string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// loadConfigFile reads a YAML or JSON (by .json extension) configuration file.
+// Keys are the environment variable names, in any case (port or PORT). Lists
+// are joined with commas and nested objects are encoded as JSON, matching the
+// formats the environment variables accept.
+func loadConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]interface{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &raw)
+	} else {
+		err = yaml.Unmarshal(data, &raw)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		str, err := configValueString(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		if value != nil {
+			values[strings.ToUpper(key)] = str
+		}
+	}
+	return values, nil
+}
+
+// configValueString converts a decoded config file value to its environment variable form
+func configValueString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ","), nil
+	case map[string]interface{}:
+		encoded, err := json.Marshal(v)
+		return string(encoded), err
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// applyConfigFile sets each file value as an environment variable unless that
+// variable is already set, so the environment always takes precedence
+func applyConfigFile(values map[string]string) {
+	for name, value := range values {
+		if _, set := os.LookupEnv(name); !set {
+			os.Setenv(name, value)
+		}
+	}
+}
+
+// validateConfig checks every configuration environment variable and returns
+// a combined error describing all problems found, or nil if the configuration
+// is valid. It has no side effects, so it is safe to call before anything else.
+func validateConfig() error {
+	var errs []error
+	check := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	check(validatePort("PORT"))
+	check(validatePort("REDIS_PORT"))
+	check(validatePort("GRPC_PORT"))
+
+	for _, name := range []string{"REDIS_PUBLISH_TIMEOUT", "REQUEST_TIMEOUT", "SECRET_RELOAD_INTERVAL", "SHUTDOWN_GRACE_PERIOD", "WEBHOOK_TIMEOUT", "REDIS_DIAL_TIMEOUT", "REDIS_HEALTHCHECK_INTERVAL", "DRAIN_TIMEOUT", "DEDUPE_TTL", "BATCH_INTERVAL", "HTTP_READ_HEADER_TIMEOUT", "HTTP_READ_TIMEOUT", "HTTP_WRITE_TIMEOUT", "HTTP_IDLE_TIMEOUT", "RETRY_MAX_BACKOFF"} {
+		check(validateEnv(name, func(value string) error {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return errors.New("not a valid duration")
+			}
+			if d < 0 {
+				return errors.New("must not be negative")
+			}
+			return nil
+		}))
+	}
+	check(validateEnv("SLACK_TIMESTAMP_TOLERANCE", func(value string) error {
+		if n, err := strconv.Atoi(value); err != nil || n <= 0 {
+			return errors.New("must be a positive integer")
+		}
+		return nil
+	}))
+	for _, name := range []string{"REDIS_DB", "RETRY_QUEUE_SIZE", "PUBLISH_WORKERS", "PUBLISH_QUEUE_SIZE", "RATE_LIMIT_BURST", "MAX_BODY_BYTES", "REDIS_POOL_SIZE", "REDIS_MIN_IDLE_CONNS", "LOG_FILE_MAX_MB", "LOG_FILE_BACKUPS", "MAX_CONCURRENT", "BATCH_SIZE", "MAX_TEXT_LENGTH", "TIMESTAMP_TOLERANCE_PAST", "TIMESTAMP_TOLERANCE_FUTURE", "GRPC_SUBSCRIBER_BUFFER", "OUTPUT_FILE_MAX_MB", "OUTPUT_FILE_BACKUPS"} {
+		check(validateEnv(name, func(value string) error {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return errors.New("not an integer")
+			}
+			if n < 0 {
+				return errors.New("must not be negative")
+			}
+			return nil
+		}))
+	}
+	check(validateEnv("RATE_LIMIT_RPS", func(value string) error {
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return errors.New("not a number")
+		}
+		return nil
+	}))
+	for _, name := range []string{"METRICS_COMMAND_LABEL", "REDIS_TLS", "REDIS_TLS_SKIP_VERIFY", "WEBHOOK_RETRY", "OTEL_ENABLED", "DEBUG_SIGNATURE_FAILURES", "USE_RESPONSE_URL", "REDIS_ENABLED", "LOG_STDERR", "ALLOW_CHANNEL_OVERRIDE", "DRY_RUN", "REDIS_METRICS", "ROUTE_BY_ENTERPRISE", "DEDUPE", "ALLOW_GET_PING", "ENABLE_EXPVAR", "EMIT_LIFECYCLE_EVENTS", "ENABLE_GRPC", "ENABLE_WEBSOCKET", "SOCKET_MODE"} {
+		check(validateEnv(name, func(value string) error {
+			if _, err := strconv.ParseBool(value); err != nil {
+				return errors.New("not a boolean")
+			}
+			return nil
+		}))
+	}
+
+	check(validateOneOf("LOG_LEVEL", "DEBUG", "INFO", "WARN", "ERROR"))
+	check(validateOneOf("LOG_FORMAT", logFormatText, logFormatJSON))
+	check(validateOneOf("REDIS_MODE", redisModePubSub, redisModeStream, redisModeList))
+	check(validateOneOf("COMPRESS_PAYLOAD", compressionNone, compressionGzip))
+	check(validateEnv("REDACT_FIELDS", func(value string) error {
+		known := commandFieldNames()
+		for _, field := range splitList(value) {
+			if !slices.Contains(known, field) {
+				return fmt.Errorf("unknown field %q, expected one of %s", field, strings.Join(known, ", "))
+			}
+		}
+		return nil
+	}))
+	check(validateOneOf("RESPONSE_TYPE", responseTypeEphemeral, responseTypeInChannel, responseTypeEmpty))
+	check(validateOneOf("TIMESTAMP_FORMAT", timestampFormatRFC3339, timestampFormatUnixMillis))
+	check(validateOneOf("TEXT_OVERFLOW", textOverflowReject, textOverflowTruncate))
+	check(validateOneOf("PAYLOAD_CASE", payloadCaseSnake, payloadCaseCamel))
+	check(validateOneOf("PARTITION_KEY", partitionKeyTeam, partitionKeyChannel, partitionKeyUser))
+	check(validateOneOf("KAFKA_PARTITION_KEY", partitionKeyTeam, partitionKeyChannel, partitionKeyUser))
+	check(validateOneOf("RATE_LIMIT_KEY", rateLimitKeyUser, rateLimitKeyTeam, "user", "team"))
+
+	allBackends := []string{backendRedis, backendKafka, backendNATS, backendSNS, backendPubSub, backendWebhook, backendStdout, backendFile}
+	configured := []string{backendRedis}
+	if value := os.Getenv("BACKENDS"); value != "" {
+		configured = splitList(value)
+		for _, name := range configured {
+			if !containsFold(allBackends, name) {
+				errs = append(errs, fmt.Errorf("BACKENDS: unknown backend %q", name))
+			}
+		}
+	} else if value := os.Getenv("BACKEND"); value != "" {
+		check(validateOneOf("BACKEND", allBackends...))
+		configured = []string{value}
+	}
+	if value := os.Getenv("REDIS_ENABLED"); value != "" {
+		if enabled, err := strconv.ParseBool(value); err == nil && !enabled {
+			configured = withoutBackend(configured, backendRedis)
+		}
+	}
+	for _, name := range configured {
+		check(validateBackendConfig(strings.ToLower(name)))
+	}
+	if _, ok := parseDeadLetterSink(os.Getenv("DEAD_LETTER_CHANNEL")).(redisDeadLetter); ok && !containsFold(configured, backendRedis) {
+		errs = append(errs, errors.New("DEAD_LETTER_CHANNEL names a Redis list but redis is not a configured backend; use a file path instead"))
+	}
+
+	check(validateEnv("RESPONSE_TEMPLATE", func(value string) error {
+		_, err := template.New("response").Parse(value)
+		return err
+	}))
+	check(validateEnv("ASYNC_RESPONSE_TEMPLATE", func(value string) error {
+		_, err := template.New("async_response").Parse(value)
+		return err
+	}))
+	check(validateEnv("ERROR_RESPONSE_TEMPLATE", func(value string) error {
+		_, err := template.New("error_response").Parse(value)
+		return err
+	}))
+	check(validateEnv("SECRETS_MAP", func(value string) error {
+		_, err := parseSecretsMap(value)
+		return err
+	}))
+	check(validateEnv("COMMAND_RESPONSES", func(value string) error {
+		_, err := parseCommandResponses(value)
+		return err
+	}))
+	check(validateEnv("COMMAND_CHANNEL_MAP", func(value string) error {
+		_, err := parseChannelMap(value)
+		return err
+	}))
+	if enabled, err := strconv.ParseBool(os.Getenv("ALLOW_CHANNEL_OVERRIDE")); err == nil && enabled {
+		if _, err := parseChannelOverridePattern(os.Getenv("CHANNEL_OVERRIDE_PATTERN")); err != nil {
+			errs = append(errs, fmt.Errorf("CHANNEL_OVERRIDE_PATTERN: %w", err))
+		}
+	}
+	check(validateEnv("WEBHOOK_URL", func(value string) error {
+		_, err := validateWebhookURL(value)
+		return err
+	}))
+	check(validateEnv("REDIS_URL", func(value string) error {
+		_, err := redis.ParseURL(value)
+		return err
+	}))
+	check(validateEnv("COMMAND_CHANNEL_ALLOWLIST", func(value string) error {
+		_, err := parseCommandChannelAllowlists(value)
+		return err
+	}))
+	check(validateEnv("NORMALIZE_COMMAND", func(value string) error {
+		_, err := parseCommandNormalization(value)
+		return err
+	}))
+	check(validateEnv("TRUSTED_IPS", func(value string) error {
+		_, err := parseTrustedIPs(value)
+		return err
+	}))
+	check(validateEnv("BIND_ADDR", func(value string) error {
+		_, err := parseBindAddrs(value, "")
+		return err
+	}))
+	if enabled, err := strconv.ParseBool(os.Getenv("SOCKET_MODE")); err == nil && enabled {
+		if err := validateAppToken(os.Getenv("SLACK_APP_TOKEN")); err != nil {
+			errs = append(errs, fmt.Errorf("SLACK_APP_TOKEN: %w", err))
+		}
+	}
+	if (os.Getenv("TLS_CERT_FILE") == "") != (os.Getenv("TLS_KEY_FILE") == "") {
+		errs = append(errs, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateBackendConfig checks that the variables a backend requires are set
+func validateBackendConfig(name string) error {
+	var missing []string
+	require := func(vars ...string) {
+		for _, v := range vars {
+			if os.Getenv(v) == "" {
+				missing = append(missing, v)
+			}
+		}
+	}
+	switch name {
+	case backendKafka:
+		require("KAFKA_BROKERS", "KAFKA_TOPIC")
+	case backendSNS:
+		require("SNS_TOPIC_ARN")
+	case backendPubSub:
+		require("GCP_PROJECT_ID", "PUBSUB_TOPIC")
+	case backendWebhook:
+		require("WEBHOOK_URL")
+	case backendFile:
+		require("OUTPUT_FILE")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("backend %s requires %s", name, strings.Join(missing, " and "))
+	}
+	return nil
+}
+
+// validateEnv runs check on the named variable's value if it is set
+func validateEnv(name string, check func(value string) error) error {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil
+	}
+	if err := check(value); err != nil {
+		return fmt.Errorf("%s=%q: %w", name, value, err)
+	}
+	return nil
+}
+
+// validateOneOf checks that the named variable, if set, is one of allowed (case-insensitive)
+func validateOneOf(name string, allowed ...string) error {
+	return validateEnv(name, func(value string) error {
+		if !containsFold(allowed, value) {
+			return fmt.Errorf("must be one of %s", strings.Join(allowed, ", "))
+		}
+		return nil
+	})
+}
+
+// validatePort checks that the named variable, if set, is a TCP port number with an optional leading colon
+func validatePort(name string) error {
+	return validateEnv(name, func(value string) error {
+		port, err := strconv.Atoi(strings.TrimPrefix(value, ":"))
+		if err != nil || port < 1 || port > 65535 {
+			return errors.New("not a valid port number")
+		}
+		return nil
+	})
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
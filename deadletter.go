@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// deadLetterTimeout bounds each write to a Redis dead-letter list
+const deadLetterTimeout = 5 * time.Second
+
+var errRedisUnavailable = errors.New("redis is not connected")
+
+// deadLetterRecord is written to the dead-letter sink for each command that
+// could not be published
+type deadLetterRecord struct {
+	FailedAt  Timestamp       `json:"failed_at"`
+	Reason    string          `json:"reason"`
+	Backend   string          `json:"backend"`
+	RequestID string          `json:"request_id"`
+	Command   string          `json:"command"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// deadLetterSink stores permanently failed commands for later inspection or recovery
+type deadLetterSink interface {
+	write(data []byte) error
+}
+
+// deadLetter is the configured sink; nil (the default) drops failed commands
+var deadLetter deadLetterSink
+
+// parseDeadLetterSink interprets DEAD_LETTER_CHANNEL: a value starting with
+// "file:", "/" or "." is a local file path, anything else a Redis list name
+func parseDeadLetterSink(value string) deadLetterSink {
+	switch {
+	case value == "":
+		return nil
+	case strings.HasPrefix(value, "file:"):
+		return &fileDeadLetter{path: strings.TrimPrefix(value, "file:")}
+	case strings.HasPrefix(value, "/"), strings.HasPrefix(value, "."):
+		return &fileDeadLetter{path: value}
+	default:
+		return redisDeadLetter{key: value}
+	}
+}
+
+// fileDeadLetter appends records to a file as newline-delimited JSON
+type fileDeadLetter struct {
+	mu   sync.Mutex
+	path string
+}
+
+func (f *fileDeadLetter) write(data []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// redisDeadLetter pushes records onto a Redis list, which keeps them until read
+type redisDeadLetter struct {
+	key string
+}
+
+func (r redisDeadLetter) write(data []byte) error {
+	client := getRedisClient()
+	if client == nil || !redisConnected.Load() {
+		return errRedisUnavailable
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), deadLetterTimeout)
+	defer cancel()
+	return client.LPush(ctx, r.key, data).Err()
+}
+
+// deadLetterItem writes a retry item that is being given up on to the
+// dead-letter sink, if one is configured
+func deadLetterItem(item retryItem, reason string) {
+	if deadLetter == nil {
+		return
+	}
+	record := deadLetterRecord{
+		FailedAt:  Timestamp(time.Now()),
+		Reason:    reason,
+		Backend:   item.backend,
+		RequestID: item.requestID,
+		Command:   item.command.Command,
+		Payload:   json.RawMessage(item.payload),
+	}
+	data, err := json.Marshal(record)
+	if err == nil {
+		err = deadLetter.write(data)
+	}
+	if err != nil {
+		logRequest(ERROR, item.requestID, "Error writing command %s to dead-letter channel: %v", item.command.Command, err)
+		return
+	}
+	logRequest(WARN, item.requestID, "Wrote command %s to dead-letter channel: %s", item.command.Command, reason)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseDeadLetterSink(t *testing.T) {
+	if parseDeadLetterSink("") != nil {
+		t.Error("expected no sink by default")
+	}
+	if sink, ok := parseDeadLetterSink("slack-commands-dlq").(redisDeadLetter); !ok || sink.key != "slack-commands-dlq" {
+		t.Errorf("expected a Redis list sink, got %#v", parseDeadLetterSink("slack-commands-dlq"))
+	}
+	for _, value := range []string{"/var/lib/relay/dlq.ndjson", "./dlq.ndjson", "file:dlq.ndjson"} {
+		if _, ok := parseDeadLetterSink(value).(*fileDeadLetter); !ok {
+			t.Errorf("expected a file sink for %q", value)
+		}
+	}
+}
+
+func TestDeadLetterItem_WritesFileRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dlq.ndjson")
+	origSink := deadLetter
+	deadLetter = parseDeadLetterSink(path)
+	t.Cleanup(func() { deadLetter = origSink })
+
+	item := retryItem{backend: "redis", command: SlackCommand{Command: "/deploy"}, requestID: "req-1", payload: []byte(`{"id":"1"}`)}
+	deadLetterItem(item, "retry queue full")
+	deadLetterItem(item, "unpublished at shutdown")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected dead-letter file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(lines))
+	}
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("expected a JSON record: %v", err)
+	}
+	if record["reason"] != "retry queue full" || record["command"] != "/deploy" || record["request_id"] != "req-1" || record["backend"] != "redis" {
+		t.Errorf("unexpected record: %v", record)
+	}
+	if payload, _ := record["payload"].(map[string]interface{}); payload["id"] != "1" {
+		t.Errorf("expected the original payload to be embedded, got %v", record["payload"])
+	}
+	if _, ok := record["failed_at"]; !ok {
+		t.Error("expected failed_at timestamp")
+	}
+}
+
+func TestRetryQueue_DeadLettersDroppedItems(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dlq.ndjson")
+	origSink := deadLetter
+	deadLetter = parseDeadLetterSink(path)
+	t.Cleanup(func() { deadLetter = origSink })
+
+	q := newRetryQueue(1, nil)
+	q.enqueue(retryItem{command: SlackCommand{Command: "/one"}, payload: []byte("{}")})
+	q.enqueue(retryItem{command: SlackCommand{Command: "/two"}, payload: []byte("{}")})
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected dead-letter file: %v", err)
+	}
+	if !strings.Contains(string(data), `"command":"/one"`) {
+		t.Errorf("expected evicted command in dead-letter file, got %s", data)
+	}
+}
+
+func TestRedisDeadLetter_RequiresRedis(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	setRedisClient(nil)
+	if err := (redisDeadLetter{key: "dlq"}).write([]byte("{}")); err == nil {
+		t.Error("expected an error without a Redis connection")
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// retryNumHeader and retryReasonHeader are set by Slack when it redelivers a request
+	retryNumHeader    = "X-Slack-Retry-Num"
+	retryReasonHeader = "X-Slack-Retry-Reason"
+
+	// defaultDedupeTTL is how long a delivery is remembered when DEDUPE_TTL is
+	// unset; Slack's last retry comes a few minutes after the original
+	defaultDedupeTTL = 10 * time.Minute
+)
+
+// dedupe drops redeliveries of a body already seen within dedupeTTL, using a
+// Redis SET NX key per body hash
+var dedupe bool
+var dedupeTTL = defaultDedupeTTL
+
+// slackRetry returns the retry attempt and reason Slack sent with a request.
+// A first delivery has no headers and yields 0 and "".
+func slackRetry(r *http.Request) (int, string) {
+	num, err := strconv.Atoi(r.Header.Get(retryNumHeader))
+	if err != nil || num < 0 {
+		num = 0
+	}
+	return num, r.Header.Get(retryReasonHeader)
+}
+
+// dedupeKey returns the Redis key that marks a body hash as delivered
+func dedupeKey(bodyHash [32]byte) string {
+	return channelPrefix + "slack:dedupe:" + hex.EncodeToString(bodyHash[:])
+}
+
+// seenBefore records a delivery and reports whether the same body was already
+// recorded within dedupeTTL. Errors mean the delivery could not be checked.
+func seenBefore(ctx context.Context, bodyHash [32]byte) (bool, error) {
+	client := getRedisClient()
+	if client == nil || !redisConnected.Load() {
+		return false, errRedisUnavailable
+	}
+	ctx, cancel := context.WithTimeout(ctx, redisPublishTimeout)
+	defer cancel()
+	first, err := client.SetNX(ctx, dedupeKey(bodyHash), 1, dedupeTTL).Result()
+	if err != nil {
+		return false, err
+	}
+	return !first, nil
+}
+
+// forgetDelivery removes a delivery recorded by seenBefore whose publish
+// failed, so Slack's redelivery of it is published instead of dropped
+func forgetDelivery(ctx context.Context, key string) {
+	client := getRedisClient()
+	if client == nil {
+		return
+	}
+	// The publish may have failed because ctx expired; the cleanup still needs to run
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), redisPublishTimeout)
+	defer cancel()
+	if err := client.Del(ctx, key).Err(); err != nil {
+		logWarn("Error removing dedupe key %s after failed publish: %v", key, err)
+	}
+}
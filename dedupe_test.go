@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestSlackRetry(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/command", nil)
+	if num, reason := slackRetry(req); num != 0 || reason != "" {
+		t.Errorf("expected no retry on a first delivery, got %d %q", num, reason)
+	}
+
+	req.Header.Set(retryNumHeader, "2")
+	req.Header.Set(retryReasonHeader, "http_timeout")
+	if num, reason := slackRetry(req); num != 2 || reason != "http_timeout" {
+		t.Errorf("expected retry 2 http_timeout, got %d %q", num, reason)
+	}
+
+	req.Header.Set(retryNumHeader, "two")
+	if num, _ := slackRetry(req); num != 0 {
+		t.Errorf("expected an invalid retry number to be ignored, got %d", num)
+	}
+}
+
+func TestDedupeKey(t *testing.T) {
+	orig := channelPrefix
+	t.Cleanup(func() { channelPrefix = orig })
+	channelPrefix = "staging:"
+
+	hash := sha256.Sum256([]byte("command=%2Fdeploy"))
+	key := dedupeKey(hash)
+	if !strings.HasPrefix(key, "staging:slack:dedupe:") || len(key) != len("staging:slack:dedupe:")+64 {
+		t.Errorf("unexpected dedupe key %q", key)
+	}
+	if other := dedupeKey(sha256.Sum256([]byte("command=%2Fstatus"))); other == key {
+		t.Error("expected different bodies to have different keys")
+	}
+}
+
+func TestSlackCommandHandler_RetryHeadersAndDedupeFailOpen(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	orig := dedupe
+	t.Cleanup(func() { dedupe = orig })
+	setSigningSecrets(nil)
+	setRedisClient(nil)
+	publishQueue = nil
+	fake := &fakePublisher{}
+	activePublisher = fake
+	// Redis is unavailable, so the duplicate check fails and the command is still published
+	dedupe = true
+
+	req := httptest.NewRequest(http.MethodPost, "/command", strings.NewReader("command=%2Fdeploy&team_id=T1"))
+	req.Header.Set(retryNumHeader, "1")
+	req.Header.Set(retryReasonHeader, "http_error")
+	w := httptest.NewRecorder()
+	slackCommandHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if len(fake.payloads) != 1 {
+		t.Fatalf("expected the command to be published, got %d publishes", len(fake.payloads))
+	}
+	var envelope struct {
+		RetryNum    int    `json:"retry_num"`
+		RetryReason string `json:"retry_reason"`
+	}
+	if err := json.Unmarshal(fake.payloads[0], &envelope); err != nil {
+		t.Fatalf("invalid published JSON: %v", err)
+	}
+	if envelope.RetryNum != 1 || envelope.RetryReason != "http_error" {
+		t.Errorf("expected retry headers in the envelope, got %+v", envelope)
+	}
+}
+
+// firstDeliveryHook records commands like recordingHook and answers SET NX as
+// though the key was new
+type firstDeliveryHook struct {
+	recordingHook
+}
+
+func (h *firstDeliveryHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		h.commands = append(h.commands, cmd.Args())
+		if setNX, ok := cmd.(*redis.BoolCmd); ok {
+			setNX.SetVal(true)
+		}
+		return nil
+	}
+}
+
+func TestSlackCommandHandler_DedupeForgetsFailedPublish(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	orig, origQueue := dedupe, publishRetryQueue
+	t.Cleanup(func() { dedupe, publishRetryQueue = orig, origQueue })
+	setSigningSecrets(nil)
+	publishQueue = nil
+	publishRetryQueue = nil
+	dedupe = true
+	hook := &firstDeliveryHook{}
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1"})
+	client.AddHook(hook)
+	defer client.Close()
+	setRedisClient(client)
+	redisConnected.Store(true)
+
+	body := "command=%2Fdeploy&team_id=T1"
+	key := dedupeKey(sha256.Sum256([]byte(body)))
+	for _, tt := range []struct {
+		name      string
+		err       error
+		forgotten bool
+	}{
+		{"published", nil, false},
+		{"publish failed", errors.New("broker down"), true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			hook.commands = nil
+			activePublisher = &fakePublisher{err: tt.err}
+
+			slackCommandHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/command", strings.NewReader(body)))
+
+			deleted := slices.ContainsFunc(hook.commands, func(args []any) bool {
+				return len(args) == 2 && args[0] == "del" && args[1] == key
+			})
+			if deleted != tt.forgotten {
+				t.Errorf("expected dedupe key deleted=%v, got commands %v", tt.forgotten, hook.commands)
+			}
+		})
+	}
+}
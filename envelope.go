@@ -0,0 +1,135 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envelopeVersion is the schema version of PublishEnvelope. Bump it when the
+// published message format changes incompatibly.
+const envelopeVersion = 1
+
+// requestIDHeader carries the correlation ID for a command, both inbound and in responses
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds inbound request IDs so callers can't inject huge values into logs
+const maxRequestIDLength = 128
+
+// PublishEnvelope wraps a Slack command with relay metadata before publishing
+type PublishEnvelope struct {
+	Version    int       `json:"version"`
+	Source     string    `json:"source"`
+	ID         string    `json:"id"`
+	RequestID  string    `json:"request_id"`
+	ReceivedAt Timestamp `json:"received_at"`
+	// BodySHA256 is the hex SHA-256 of the raw request body, for auditing
+	BodySHA256 string       `json:"body_sha256"`
+	Command    SlackCommand `json:"command"`
+	// Args is Command.Text split into shell-style arguments by ParseArgs
+	Args []string `json:"args"`
+	// Trace carries the W3C trace context (traceparent) when tracing is enabled
+	Trace map[string]string `json:"trace,omitempty"`
+	// RetryNum and RetryReason echo Slack's X-Slack-Retry-* headers on redeliveries
+	RetryNum    int    `json:"retry_num,omitempty"`
+	RetryReason string `json:"retry_reason,omitempty"`
+	// Replay is set when an operator re-published the command through /replay
+	Replay bool `json:"replay,omitempty"`
+	// TextTruncated is set when Command.Text was cut to MAX_TEXT_LENGTH
+	TextTruncated bool `json:"text_truncated,omitempty"`
+	// OriginalCommand is the command name as sent, when NORMALIZE_COMMAND is set
+	OriginalCommand string `json:"original_command,omitempty"`
+}
+
+// relaySource identifies this relay instance in published envelopes
+var relaySource = "slack-command-relay"
+
+// newPublishEnvelope wraps a command received at the given time in a new envelope
+func newPublishEnvelope(command SlackCommand, requestID string, receivedAt time.Time) PublishEnvelope {
+	return PublishEnvelope{
+		Version:    envelopeVersion,
+		Source:     relaySource,
+		ID:         newUUID(),
+		RequestID:  requestID,
+		ReceivedAt: Timestamp(receivedAt),
+		Command:    command,
+		Args:       ParseArgs(command.Text),
+	}
+}
+
+// redactedValue replaces the values of fields listed in REDACT_FIELDS
+const redactedValue = "[REDACTED]"
+
+// redactFields holds the JSON names of command fields masked before publishing.
+// The verification token is redacted by default so it doesn't reach every subscriber.
+var redactFields = map[string]bool{"token": true}
+
+// commandFieldNames returns the JSON names of the SlackCommand fields
+func commandFieldNames() []string {
+	t := reflect.TypeOf(SlackCommand{})
+	names := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		names = append(names, name)
+	}
+	return names
+}
+
+// redact masks the non-empty command fields whose JSON names are in fields.
+// Redacting text also drops args, which are derived from it.
+func (e *PublishEnvelope) redact(fields map[string]bool) {
+	if len(fields) == 0 {
+		return
+	}
+	e.Command = redactCommand(e.Command, fields)
+	if fields["text"] {
+		e.Args = []string{}
+	}
+}
+
+// redactCommand returns a copy of command with the non-empty fields whose JSON
+// names are in fields masked. Backends build partition keys, stream fields and
+// message attributes from this copy so redacted values don't leak through them.
+func redactCommand(command SlackCommand, fields map[string]bool) SlackCommand {
+	v := reflect.ValueOf(&command).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if fields[name] && v.Field(i).String() != "" {
+			v.Field(i).SetString(redactedValue)
+		}
+	}
+	return command
+}
+
+// requestIDFor returns the request's X-Request-ID header, or a new UUID if it has none
+func requestIDFor(r *http.Request) string {
+	if id := r.Header.Get(requestIDHeader); id != "" && len(id) <= maxRequestIDLength {
+		return id
+	}
+	return newUUID()
+}
+
+// newUUID returns a random (version 4) UUID string
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// Timestamp is a time that marshals to JSON using the configured TIMESTAMP_FORMAT
+type Timestamp time.Time
+
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	if timestampFormat == timestampFormatUnixMillis {
+		return []byte(strconv.FormatInt(time.Time(t).UnixMilli(), 10)), nil
+	}
+	return json.Marshal(time.Time(t).UTC().Format(time.RFC3339Nano))
+}
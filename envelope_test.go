@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimestampMarshalJSON(t *testing.T) {
+	orig := timestampFormat
+	t.Cleanup(func() { timestampFormat = orig })
+
+	ts := Timestamp(time.Date(2024, 1, 2, 3, 4, 5, 6000000, time.UTC))
+
+	timestampFormat = timestampFormatRFC3339
+	got, err := json.Marshal(ts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(got) != `"2024-01-02T03:04:05.006Z"` {
+		t.Errorf("unexpected RFC 3339 timestamp: %s", got)
+	}
+
+	timestampFormat = timestampFormatUnixMillis
+	got, err = json.Marshal(ts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(got) != "1704164645006" {
+		t.Errorf("unexpected Unix millis timestamp: %s", got)
+	}
+}
+
+func TestNewUUID(t *testing.T) {
+	pattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	first, second := newUUID(), newUUID()
+
+	if !pattern.MatchString(first) {
+		t.Errorf("expected a version 4 UUID, got %q", first)
+	}
+	if first == second {
+		t.Error("expected distinct UUIDs")
+	}
+}
+
+func TestNewPublishEnvelope(t *testing.T) {
+	receivedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	envelope := newPublishEnvelope(SlackCommand{Command: "/deploy"}, "req-1", receivedAt)
+
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, field := range []string{"version", "source", "id", "request_id", "received_at", "body_sha256", "command", "args"} {
+		if _, ok := decoded[field]; !ok {
+			t.Errorf("expected envelope field %q in %s", field, data)
+		}
+	}
+	if decoded["version"] != float64(envelopeVersion) {
+		t.Errorf("expected version %d, got %v", envelopeVersion, decoded["version"])
+	}
+	if decoded["request_id"] != "req-1" {
+		t.Errorf("expected request_id req-1, got %v", decoded["request_id"])
+	}
+	if command, _ := decoded["command"].(map[string]interface{}); command["command"] != "/deploy" {
+		t.Errorf("expected nested command, got %v", decoded["command"])
+	}
+}
+
+func TestRequestIDFor(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/command", nil)
+	req.Header.Set(requestIDHeader, "upstream-id")
+	if got := requestIDFor(req); got != "upstream-id" {
+		t.Errorf("expected inbound request ID to be reused, got %q", got)
+	}
+
+	req.Header.Set(requestIDHeader, strings.Repeat("x", maxRequestIDLength+1))
+	if got := requestIDFor(req); len(got) != 36 {
+		t.Errorf("expected oversized request ID to be replaced with a UUID, got %q", got)
+	}
+
+	req.Header.Del(requestIDHeader)
+	if got := requestIDFor(req); len(got) != 36 {
+		t.Errorf("expected a generated UUID, got %q", got)
+	}
+}
+
+func TestPublishEnvelope_Redact(t *testing.T) {
+	command := SlackCommand{Token: "secret-token", Command: "/deploy", Text: "prod now", ResponseURL: "https://hooks.slack.com/x"}
+	envelope := newPublishEnvelope(command, "req-1", time.Now())
+
+	envelope.redact(map[string]bool{"token": true, "text": true, "trigger_id": true})
+
+	if envelope.Command.Token != redactedValue || envelope.Command.Text != redactedValue {
+		t.Errorf("expected token and text to be redacted, got %+v", envelope.Command)
+	}
+	if envelope.Command.TriggerID != "" {
+		t.Errorf("expected empty fields to stay empty, got %q", envelope.Command.TriggerID)
+	}
+	if envelope.Command.Command != "/deploy" || envelope.Command.ResponseURL != command.ResponseURL {
+		t.Errorf("expected other fields untouched, got %+v", envelope.Command)
+	}
+	if len(envelope.Args) != 0 {
+		t.Errorf("expected args derived from redacted text to be dropped, got %q", envelope.Args)
+	}
+}
+
+func TestSlackCommandHandler_RedactsBackendFields(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	setSigningSecrets(nil)
+	publishQueue = nil
+	fake := &fakePublisher{}
+	activePublisher = fake
+	origFields := redactFields
+	t.Cleanup(func() { redactFields = origFields })
+	redactFields = map[string]bool{"token": true, "team_id": true, "user_id": true}
+
+	body := "command=%2Fdeploy&team_id=T1&user_id=U1&channel_id=C1&token=secret"
+	w := httptest.NewRecorder()
+	slackCommandHandler(w, httptest.NewRequest(http.MethodPost, "/command", strings.NewReader(body)))
+	if w.Code != http.StatusOK || len(fake.metas) != 1 {
+		t.Fatalf("expected one publish, got %d with %d publishes", w.Code, len(fake.metas))
+	}
+
+	// Stream fields, message attributes and partition keys come from the command in the metadata
+	got := fake.metas[0].command
+	if got.TeamID != redactedValue || got.UserID != redactedValue || got.Token != redactedValue {
+		t.Errorf("expected redacted fields in the publish metadata, got %+v", got)
+	}
+	if got.Command != "/deploy" || got.ChannelID != "C1" {
+		t.Errorf("expected other fields untouched, got %+v", got)
+	}
+	if fake.keys[0] != redactedValue {
+		t.Errorf("expected the partition key to be redacted, got %q", fake.keys[0])
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"text/template"
+)
+
+// publishFailureMessage is the reason given when a synchronous publish fails
+const publishFailureMessage = "The command could not be delivered right now."
+
+// errorResponseTemplate renders ERROR_RESPONSE_TEMPLATE, the message shown to
+// users whose command was rejected or could not be published. When it is nil
+// the built-in responses are used.
+var errorResponseTemplate *template.Template
+
+// errorResponseData is the ERROR_RESPONSE_TEMPLATE context: the command's
+// fields plus Reason, the message that would otherwise have been shown
+type errorResponseData struct {
+	SlackCommand
+	Reason string
+}
+
+// errorResponseText returns the text shown to the user for a command that
+// failed for reason: the rendered ERROR_RESPONSE_TEMPLATE, or reason itself
+func errorResponseText(command SlackCommand, reason string) string {
+	if errorResponseTemplate == nil {
+		return reason
+	}
+	var text strings.Builder
+	if err := errorResponseTemplate.Execute(&text, errorResponseData{SlackCommand: command, Reason: reason}); err != nil {
+		logError("Error rendering error response template: %v", err)
+		return reason
+	}
+	return text.String()
+}
+
+// writeCommandError rejects a command. Slack only shows the body of a 200
+// response, so with ERROR_RESPONSE_TEMPLATE the message is sent as an ephemeral
+// reply; otherwise reason is returned as a plain HTTP error with status.
+func writeCommandError(w http.ResponseWriter, status int, command SlackCommand, reason string) {
+	if errorResponseTemplate == nil {
+		http.Error(w, reason, status)
+		return
+	}
+	writeEphemeralResponse(w, http.StatusOK, errorResponseText(command, reason))
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestErrorResponseText(t *testing.T) {
+	orig := errorResponseTemplate
+	t.Cleanup(func() { errorResponseTemplate = orig })
+	command := SlackCommand{Command: "/deploy", UserName: "alice"}
+
+	errorResponseTemplate = nil
+	if got := errorResponseText(command, "Team not allowed"); got != "Team not allowed" {
+		t.Errorf("expected the reason without a template, got %q", got)
+	}
+
+	errorResponseTemplate = template.Must(template.New("error_response").Parse("Sorry {{.UserName}}, {{.Command}} failed: {{.Reason}}"))
+	if got := errorResponseText(command, "Team not allowed"); got != "Sorry alice, /deploy failed: Team not allowed" {
+		t.Errorf("unexpected rendered text %q", got)
+	}
+
+	// A template that fails at execution falls back to the reason
+	errorResponseTemplate = template.Must(template.New("error_response").Parse("{{.Missing}}"))
+	if got := errorResponseText(command, "Team not allowed"); got != "Team not allowed" {
+		t.Errorf("expected the reason when rendering fails, got %q", got)
+	}
+}
+
+func TestSlackCommandHandler_ErrorResponseTemplate(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	setSigningSecrets(nil)
+	orig := errorResponseTemplate
+	t.Cleanup(func() { errorResponseTemplate = orig })
+	errorResponseTemplate = template.Must(template.New("error_response").Parse("{{.Command}}: {{.Reason}} Contact #help."))
+
+	send := func(body string) SlackResponse {
+		t.Helper()
+		w := httptest.NewRecorder()
+		slackCommandHandler(w, httptest.NewRequest(http.MethodPost, "/command", strings.NewReader(body)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200 so Slack shows the message, got %d", w.Code)
+		}
+		var response SlackResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("invalid JSON response: %v", err)
+		}
+		return response
+	}
+
+	teamAllowlist = toSet([]string{"T1"})
+	if got := send("command=%2Fdeploy&team_id=T2"); got.Text != "/deploy: Team not allowed Contact #help." || got.ResponseType != responseTypeEphemeral {
+		t.Errorf("unexpected team rejection %+v", got)
+	}
+
+	teamAllowlist = nil
+	activePublisher = &fakePublisher{err: errors.New("redis down")}
+	if got := send("command=%2Fdeploy&team_id=T1"); got.Text != "/deploy: "+publishFailureMessage+" Contact #help." {
+		t.Errorf("unexpected publish failure response %+v", got)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+)
+
+// urlVerification is the handshake Slack sends when an Events API request URL is configured
+type urlVerification struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+}
+
+// handleURLVerification answers an Events API url_verification challenge by
+// echoing the challenge value. It reports false, writing nothing, for any
+// other body so form-encoded commands continue through the normal path.
+func handleURLVerification(w http.ResponseWriter, requestID string, body []byte) bool {
+	if !bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")) {
+		return false
+	}
+	var verification urlVerification
+	if err := json.Unmarshal(body, &verification); err != nil || verification.Type != "url_verification" {
+		return false
+	}
+
+	logRequest(INFO, requestID, "Answered Slack Events API url_verification challenge")
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(verification.Challenge))
+	return true
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSlackCommandHandler_URLVerification(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	setSigningSecrets(nil)
+	publisher := &fakePublisher{}
+	activePublisher = publisher
+
+	req := httptest.NewRequest(http.MethodPost, "/command", strings.NewReader(`{"token":"x","challenge":"3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P","type":"url_verification"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	slackCommandHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if got := w.Body.String(); got != "3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P" {
+		t.Errorf("expected challenge to be echoed, got %q", got)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/plain" {
+		t.Errorf("expected text/plain, got %q", ct)
+	}
+	if len(publisher.payloads) != 0 {
+		t.Error("expected the challenge not to be published")
+	}
+}
+
+func TestHandleURLVerification_IgnoresOtherBodies(t *testing.T) {
+	for _, body := range []string{
+		"command=%2Fdeploy&text=%7B%22type%22%3A%22url_verification%22%7D",
+		`{"type":"event_callback"}`,
+		`{"type":`,
+	} {
+		w := httptest.NewRecorder()
+		if handleURLVerification(w, "req-1", []byte(body)) {
+			t.Errorf("expected %q not to be handled as url_verification", body)
+		}
+		if w.Body.Len() != 0 {
+			t.Errorf("expected nothing written for %q", body)
+		}
+	}
+}
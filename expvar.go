@@ -0,0 +1,44 @@
+package main
+
+import (
+	"expvar"
+	"net/http"
+	"runtime"
+)
+
+// expvarPath is where the expvar package serves its variables
+const expvarPath = "/debug/vars"
+
+// enableExpvar serves runtime and relay internals at expvarPath, from ENABLE_EXPVAR
+var enableExpvar bool
+
+// publishExpvars publishes the relay's expvar variables alongside the
+// package's own cmdline and memstats. It must only be called once.
+func publishExpvars() {
+	expvar.Publish("goroutines", expvar.Func(func() any {
+		return runtime.NumGoroutine()
+	}))
+	expvar.Publish("redis_pool", expvar.Func(func() any {
+		client := getRedisClient()
+		if client == nil {
+			return nil
+		}
+		return client.PoolStats()
+	}))
+	expvar.Publish("commands", expvar.Func(func() any {
+		return currentStats()
+	}))
+}
+
+// hideExpvar wraps a handler so expvarPath returns 404 unless ENABLE_EXPVAR is
+// set. Importing expvar, as some dependencies do, registers it on the default
+// mux unconditionally.
+func hideExpvar(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == expvarPath && !enableExpvar {
+			http.NotFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
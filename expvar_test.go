@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHideExpvar(t *testing.T) {
+	orig := enableExpvar
+	t.Cleanup(func() { enableExpvar = orig })
+	handler := hideExpvar(http.DefaultServeMux)
+
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, expvarPath, nil))
+		return w
+	}
+
+	enableExpvar = false
+	if w := get(); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 while disabled, got %d", w.Code)
+	}
+
+	enableExpvar = true
+	publishExpvars()
+	w := get()
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 while enabled, got %d", w.Code)
+	}
+	var vars map[string]json.RawMessage
+	if err := json.Unmarshal(w.Body.Bytes(), &vars); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	for _, name := range []string{"goroutines", "redis_pool", "commands"} {
+		if _, ok := vars[name]; !ok {
+			t.Errorf("expected %s to be published", name)
+		}
+	}
+}
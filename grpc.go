@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/its-the-vibe/SlackCommandRelay/relaypb"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Defaults for the gRPC Subscribe server
+const (
+	defaultGRPCPort             = "50051"
+	defaultGRPCSubscriberBuffer = 256
+)
+
+// enableGRPC serves the Subscribe stream on GRPC_PORT, from ENABLE_GRPC
+var enableGRPC bool
+
+// grpcSubscriberBuffer is how many commands, from GRPC_SUBSCRIBER_BUFFER, a
+// subscriber may fall behind by before commands are dropped for it
+var grpcSubscriberBuffer = defaultGRPCSubscriberBuffer
+
+// commandStream fans received commands out to the gRPC subscribers
+var commandStream = newCommandHub()
+
+// grpcServer is the running Subscribe server, stopped at shutdown
+var grpcServer *grpc.Server
+
+var (
+	grpcSubscribers = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "slack_grpc_subscribers",
+		Help: "Number of gRPC clients subscribed to the command stream.",
+	})
+
+	grpcDroppedCommands = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "slack_grpc_dropped_commands_total",
+		Help: "Total number of commands dropped for gRPC subscribers that fell behind.",
+	})
+)
+
+// commandHub broadcasts commands to subscribers, each with its own bounded
+// buffer. A full buffer drops the command for that subscriber only, so a slow
+// consumer never delays the handler or the other subscribers.
+type commandHub struct {
+	mu          sync.Mutex
+	subscribers map[chan *relaypb.Command]bool
+	closed      bool
+}
+
+func newCommandHub() *commandHub {
+	return &commandHub{subscribers: make(map[chan *relaypb.Command]bool)}
+}
+
+// subscribe registers a subscriber with a buffer of size commands. The
+// channel is closed when the hub closes.
+func (h *commandHub) subscribe(size int) chan *relaypb.Command {
+	ch := make(chan *relaypb.Command, size)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(ch)
+		return ch
+	}
+	h.subscribers[ch] = true
+	grpcSubscribers.Inc()
+	return ch
+}
+
+// unsubscribe removes a subscriber, closing its channel
+func (h *commandHub) unsubscribe(ch chan *relaypb.Command) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subscribers[ch] {
+		delete(h.subscribers, ch)
+		close(ch)
+		grpcSubscribers.Dec()
+	}
+}
+
+// broadcast offers a command to every subscriber without blocking
+func (h *commandHub) broadcast(command *relaypb.Command) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers {
+		select {
+		case ch <- command:
+		default:
+			grpcDroppedCommands.Inc()
+			logRequest(WARN, command.RequestId, "gRPC subscriber fell behind, dropped command %s", command.Command)
+		}
+	}
+}
+
+// close ends every subscription so their streams return
+func (h *commandHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers {
+		delete(h.subscribers, ch)
+		close(ch)
+		grpcSubscribers.Dec()
+	}
+	h.closed = true
+}
+
+// streamEnvelope sends a command to the gRPC subscribers when ENABLE_GRPC is set
+func streamEnvelope(envelope PublishEnvelope) {
+	if !enableGRPC {
+		return
+	}
+	commandStream.broadcast(commandMessage(envelope))
+}
+
+// commandMessage converts an envelope to its protobuf form
+func commandMessage(envelope PublishEnvelope) *relaypb.Command {
+	command := envelope.Command
+	return &relaypb.Command{
+		Id:              envelope.ID,
+		RequestId:       envelope.RequestID,
+		ReceivedAt:      timestamppb.New(time.Time(envelope.ReceivedAt)),
+		Token:           command.Token,
+		TeamId:          command.TeamID,
+		TeamDomain:      command.TeamDomain,
+		ChannelId:       command.ChannelID,
+		ChannelName:     command.ChannelName,
+		UserId:          command.UserID,
+		UserName:        command.UserName,
+		Command:         command.Command,
+		Text:            command.Text,
+		ResponseUrl:     command.ResponseURL,
+		TriggerId:       command.TriggerID,
+		ApiAppId:        command.APIAppID,
+		EnterpriseId:    command.EnterpriseID,
+		EnterpriseName:  command.EnterpriseName,
+		Args:            envelope.Args,
+		TextTruncated:   envelope.TextTruncated,
+		OriginalCommand: envelope.OriginalCommand,
+	}
+}
+
+// commandRelayServer implements the CommandRelay service on a commandHub
+type commandRelayServer struct {
+	relaypb.UnimplementedCommandRelayServer
+	hub *commandHub
+}
+
+// Subscribe streams commands until the client goes away or the relay shuts down
+func (s *commandRelayServer) Subscribe(_ *relaypb.SubscribeRequest, stream grpc.ServerStreamingServer[relaypb.Command]) error {
+	ch := s.hub.subscribe(grpcSubscriberBuffer)
+	defer s.hub.unsubscribe(ch)
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case command, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(command); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// grpcAdminTokenKey is the metadata key clients send the admin token in,
+// the lowercase form gRPC gives adminTokenHeader
+var grpcAdminTokenKey = strings.ToLower(adminTokenHeader)
+
+// grpcAuthorized returns an Unauthenticated error unless the call's metadata
+// carries the admin token
+func grpcAuthorized(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, token := range md.Get(grpcAdminTokenKey) {
+		if validAdminToken(token) {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "invalid admin token")
+}
+
+// requireAdminTokenUnary rejects unary calls without the admin token
+func requireAdminTokenUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := grpcAuthorized(ctx); err != nil {
+		logWarn("Rejected gRPC call %s with an invalid admin token", info.FullMethod)
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// requireAdminTokenStream rejects streaming calls without the admin token
+func requireAdminTokenStream(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := grpcAuthorized(stream.Context()); err != nil {
+		logWarn("Rejected gRPC call %s with an invalid admin token", info.FullMethod)
+		return err
+	}
+	return handler(srv, stream)
+}
+
+// newGRPCServer creates a server for the CommandRelay service on hub. Every
+// call must carry the admin token, as subscribers receive each command's
+// response_url and anything else REDACT_FIELDS leaves in place.
+func newGRPCServer(hub *commandHub) *grpc.Server {
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(requireAdminTokenUnary),
+		grpc.StreamInterceptor(requireAdminTokenStream),
+	)
+	relaypb.RegisterCommandRelayServer(server, &commandRelayServer{hub: hub})
+	return server
+}
+
+// startGRPCServer serves the CommandRelay service on port, reporting a
+// failure to serve on errs. A leading colon, as PORT allows, is ignored.
+func startGRPCServer(port string, errs chan<- error) (*grpc.Server, error) {
+	listener, err := net.Listen("tcp", ":"+strings.TrimPrefix(port, ":"))
+	if err != nil {
+		return nil, err
+	}
+	server := newGRPCServer(commandStream)
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			errs <- err
+		}
+	}()
+	return server, nil
+}
+
+// stopGRPCServer ends the open streams and stops the server
+func stopGRPCServer(server *grpc.Server) {
+	commandStream.close()
+	server.GracefulStop()
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/its-the-vibe/SlackCommandRelay/relaypb"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestCommandHub_DropsForSlowSubscriber(t *testing.T) {
+	hub := newCommandHub()
+	slow := hub.subscribe(1)
+	fast := hub.subscribe(3)
+	before := testutil.ToFloat64(grpcDroppedCommands)
+
+	for _, name := range []string{"/a", "/b", "/c"} {
+		hub.broadcast(&relaypb.Command{Command: name})
+	}
+
+	if len(slow) != 1 || (<-slow).Command != "/a" {
+		t.Errorf("expected the slow subscriber to keep only the first command")
+	}
+	if len(fast) != 3 {
+		t.Errorf("expected the fast subscriber to receive every command, got %d", len(fast))
+	}
+	if got := testutil.ToFloat64(grpcDroppedCommands) - before; got != 2 {
+		t.Errorf("expected 2 dropped commands, got %v", got)
+	}
+
+	hub.unsubscribe(slow)
+	hub.close()
+	if _, ok := <-slow; ok {
+		t.Error("expected the unsubscribed channel to be closed")
+	}
+	for range fast {
+	}
+	if _, ok := <-hub.subscribe(1); ok || len(hub.subscribers) != 0 {
+		t.Error("expected a subscription after close to be closed immediately")
+	}
+}
+
+// startTestGRPCServer serves a CommandRelay on hub, requiring the admin token
+// "admin-secret", and returns a connected client
+func startTestGRPCServer(t *testing.T, hub *commandHub) relaypb.CommandRelayClient {
+	t.Helper()
+	origToken := adminToken
+	t.Cleanup(func() { adminToken = origToken })
+	adminToken = []byte("admin-secret")
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newGRPCServer(hub)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return relaypb.NewCommandRelayClient(conn)
+}
+
+// waitForSubscribers waits until hub has n subscribers
+func waitForSubscribers(t *testing.T, hub *commandHub, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		hub.mu.Lock()
+		count := len(hub.subscribers)
+		hub.mu.Unlock()
+		if count == n {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d subscribers", n)
+}
+
+func TestGRPCSubscribe_StreamsReceivedCommands(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	setSigningSecrets(nil)
+	publishQueue = nil
+	activePublisher = &fakePublisher{}
+	origEnabled, origStream := enableGRPC, commandStream
+	t.Cleanup(func() { enableGRPC, commandStream = origEnabled, origStream })
+	enableGRPC = true
+	commandStream = newCommandHub()
+
+	client := startTestGRPCServer(t, commandStream)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, grpcAdminTokenKey, "admin-secret")
+	stream, err := client.Subscribe(ctx, &relaypb.SubscribeRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForSubscribers(t, commandStream, 1)
+
+	body := "command=%2Fdeploy&team_id=T1&user_name=alice&text=api+production&token=secret"
+	w := httptest.NewRecorder()
+	slackCommandHandler(w, httptest.NewRequest(http.MethodPost, "/command", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	command, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if command.Command != "/deploy" || command.UserName != "alice" || strings.Join(command.Args, " ") != "api production" {
+		t.Errorf("unexpected command %+v", command)
+	}
+	if command.Token != redactedValue {
+		t.Errorf("expected the token to be redacted, got %q", command.Token)
+	}
+	if command.Id == "" || command.ReceivedAt == nil {
+		t.Errorf("expected envelope metadata, got %+v", command)
+	}
+
+	// Closing the hub at shutdown ends the stream
+	commandStream.close()
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Errorf("expected the stream to end, got %v", err)
+	}
+}
+
+func TestGRPCSubscribe_RequiresAdminToken(t *testing.T) {
+	hub := newCommandHub()
+	client := startTestGRPCServer(t, hub)
+
+	for _, token := range []string{"", "wrong"} {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if token != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, grpcAdminTokenKey, token)
+		}
+		stream, err := client.Subscribe(ctx, &relaypb.SubscribeRequest{})
+		if err == nil {
+			_, err = stream.Recv()
+		}
+		cancel()
+		if status.Code(err) != codes.Unauthenticated {
+			t.Errorf("token %q: expected Unauthenticated, got %v", token, err)
+		}
+	}
+	if len(hub.subscribers) != 0 {
+		t.Error("expected rejected calls not to subscribe")
+	}
+}
+
+func TestStartGRPCServer_AcceptsLeadingColon(t *testing.T) {
+	server, err := startGRPCServer(":0", make(chan error, 1))
+	if err != nil {
+		t.Fatalf("expected a port with a leading colon to listen, got %v", err)
+	}
+	server.Stop()
+}
+
+func TestStreamEnvelope_Disabled(t *testing.T) {
+	origEnabled, origStream := enableGRPC, commandStream
+	t.Cleanup(func() { enableGRPC, commandStream = origEnabled, origStream })
+	enableGRPC = false
+	commandStream = newCommandHub()
+	ch := commandStream.subscribe(1)
+
+	streamEnvelope(newPublishEnvelope(SlackCommand{Command: "/deploy"}, "req-1", time.Now()))
+	if len(ch) != 0 {
+		t.Error("expected nothing streamed without ENABLE_GRPC")
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// defaultInteractiveChannel is the Redis channel for interactive payloads when
+// REDIS_INTERACTIVE_CHANNEL is unset
+const defaultInteractiveChannel = "slack-interactions"
+
+// defaultShortcutChannel is the Redis channel for shortcut payloads when
+// REDIS_SHORTCUT_CHANNEL is unset
+const defaultShortcutChannel = "slack-shortcuts"
+
+// Interactive payload types sent for global and message shortcuts
+const (
+	payloadTypeShortcut      = "shortcut"
+	payloadTypeMessageAction = "message_action"
+)
+
+// interactiveChannel receives interactive component payloads (button clicks,
+// menu selections, modal submissions)
+var interactiveChannel = defaultInteractiveChannel
+
+// shortcutChannel receives global and message shortcut payloads
+var shortcutChannel = defaultShortcutChannel
+
+// InteractivePayload holds the fields of a Slack interactive payload the relay
+// needs for verification and routing. The payload is published unchanged.
+type InteractivePayload struct {
+	Type       string `json:"type"`
+	Token      string `json:"token"`
+	CallbackID string `json:"callback_id"`
+	Team       struct {
+		ID string `json:"id"`
+	} `json:"team"`
+	User struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	} `json:"user"`
+	Channel struct {
+		ID string `json:"id"`
+	} `json:"channel"`
+}
+
+// isShortcut reports whether the payload is a global or message shortcut
+func (p InteractivePayload) isShortcut() bool {
+	return p.Type == payloadTypeShortcut || p.Type == payloadTypeMessageAction
+}
+
+// handleInteractivePayload verifies and publishes the JSON sent in the payload
+// form field of an interactive request. Slack only needs an empty 200 as acknowledgement.
+func handleInteractivePayload(ctx context.Context, w http.ResponseWriter, requestID, raw string) {
+	var payload InteractivePayload
+	if err := json.Unmarshal([]byte(raw), &payload); err != nil {
+		logRequest(WARN, requestID, "Invalid interactive payload: %v", err)
+		http.Error(w, "Error parsing interactive payload", http.StatusBadRequest)
+		return
+	}
+
+	if !verifyToken(verificationToken, payload.Token) {
+		logRequest(WARN, requestID, "Invalid Slack verification token for %s payload from team %s", payload.Type, payload.Team.ID)
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+	if teamAllowlist != nil && !teamAllowlist[payload.Team.ID] {
+		logRequest(WARN, requestID, "Rejected %s payload from team not in allowlist: %s", payload.Type, payload.Team.ID)
+		http.Error(w, "Team not allowed", http.StatusForbidden)
+		return
+	}
+
+	// Stream mode duplicates these fields alongside the payload; the type stands in for the command
+	channel := interactiveChannel
+	fields := SlackCommand{Command: payload.Type, TeamID: payload.Team.ID, UserID: payload.User.ID, ChannelID: payload.Channel.ID}
+	if payload.isShortcut() {
+		// Shortcuts are told apart by callback ID, so consumers route on that instead
+		channel = shortcutChannel
+		fields.Command = payload.CallbackID
+		logRequest(INFO, requestID, "Received Slack %s payload %s from user %s", payload.Type, payload.CallbackID, payload.User.Username)
+	} else {
+		logRequest(INFO, requestID, "Received Slack %s payload from user %s", payload.Type, payload.User.Username)
+	}
+	logRequest(DEBUG, requestID, "Slack interactive payload: %s", raw)
+
+	if dryRun {
+		logRequest(INFO, requestID, "[DRY RUN] would publish %s payload to %s", payload.Type, channel)
+	} else if !hasBackend(backendRedis) {
+		logRequest(WARN, requestID, "Dropping %s payload: interactive payloads are only published to Redis", payload.Type)
+		publishFailures.Inc()
+		statsPublishFailures.Add(1)
+	} else if !redisConnected.Load() {
+		logRequest(ERROR, requestID, "Error publishing %s payload to Redis %s '%s': %v", payload.Type, redisMode, channel, errRedisUnavailable)
+		publishFailures.Inc()
+		statsPublishFailures.Add(1)
+		retryInteractive(requestID, channel, fields, raw)
+	} else {
+		publishCtx, cancel := context.WithTimeout(ctx, redisPublishTimeout)
+		defer cancel()
+		if err := publishToRedis(publishCtx, channel, partitionKeyFor(fields), fields, []byte(raw), false); err != nil {
+			logRequest(ERROR, requestID, "Error publishing %s payload to Redis %s '%s': %v", payload.Type, redisMode, channel, err)
+			publishFailures.Inc()
+			statsPublishFailures.Add(1)
+			retryInteractive(requestID, channel, fields, raw)
+		} else {
+			statsPublished.Add(1)
+			logRequest(INFO, requestID, "Published %s payload to Redis %s: %s", payload.Type, redisMode, channel)
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// interactivePublisher retries interactive payloads on Redis. Unlike
+// redisPublisher it publishes to the channel override as-is and leaves the
+// command counters alone, as the handler does.
+type interactivePublisher struct{}
+
+func (interactivePublisher) Publish(ctx context.Context, key string, payload []byte) error {
+	if !redisConnected.Load() {
+		return errRedisUnavailable
+	}
+	ctx, cancel := context.WithTimeout(ctx, redisPublishTimeout)
+	defer cancel()
+	return publishToRedis(ctx, channelOverrideFrom(ctx), key, publishMetaFrom(ctx).command, payload, false)
+}
+
+func (interactivePublisher) Close() error {
+	return nil
+}
+
+// retryInteractive queues a failed interactive publish for retry on its channel
+func retryInteractive(requestID, channel string, fields SlackCommand, raw string) {
+	if publishRetryQueue == nil {
+		return
+	}
+	publishRetryQueue.enqueue(retryItem{target: interactivePublisher{}, backend: backendRedis, key: partitionKeyFor(fields), command: fields, channel: channel, payload: []byte(raw), requestID: requestID})
+}
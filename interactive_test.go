@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func interactiveRequest(payload string) *http.Request {
+	body := url.Values{"payload": {payload}}.Encode()
+	return httptest.NewRequest(http.MethodPost, "/command", strings.NewReader(body))
+}
+
+func TestSlackCommandHandler_InteractivePayload(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	setSigningSecrets(nil)
+	publisher := &fakePublisher{}
+	activePublisher = publisher
+	backends = []string{backendRedis}
+	// Nothing listens on port 1, so the publish fails fast
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer client.Close()
+	setRedisClient(client)
+	redisConnected.Store(true)
+	failures := statsPublishFailures.Load()
+
+	w := httptest.NewRecorder()
+	slackCommandHandler(w, interactiveRequest(`{"type":"block_actions","team":{"id":"T1"},"user":{"id":"U1","username":"steve"}}`))
+
+	if w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Errorf("expected empty 200 acknowledgement, got %d %q", w.Code, w.Body.String())
+	}
+	if len(publisher.payloads) != 0 {
+		t.Error("expected interactive payload not to be published as a command")
+	}
+	if statsPublishFailures.Load()-failures != 1 {
+		t.Error("expected the interactive publish to be attempted on Redis")
+	}
+}
+
+func TestSlackCommandHandler_InteractivePayloadRedisDisconnected(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	setSigningSecrets(nil)
+	activePublisher = &fakePublisher{}
+	backends = []string{backendRedis}
+	redisConnected.Store(false)
+	origQueue := publishRetryQueue
+	publishRetryQueue = newRetryQueue(10, nil)
+	t.Cleanup(func() { publishRetryQueue = origQueue })
+	failures := statsPublishFailures.Load()
+
+	w := httptest.NewRecorder()
+	slackCommandHandler(w, interactiveRequest(`{"type":"block_actions","team":{"id":"T1"},"user":{"id":"U1","username":"steve"}}`))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected a disconnected Redis not to fail the request, got %d", w.Code)
+	}
+	if statsPublishFailures.Load()-failures != 1 {
+		t.Error("expected the dropped payload to count as a publish failure")
+	}
+	item, ok := publishRetryQueue.peek()
+	if !ok {
+		t.Fatal("expected the payload to be queued for retry")
+	}
+	if _, ok := item.target.(interactivePublisher); !ok || item.channel != interactiveChannel || item.key != "T1" || item.command.Command != "block_actions" || item.requestID == "" {
+		t.Errorf("unexpected retry item: %+v", item)
+	}
+}
+
+// recordingHook captures the commands sent to Redis without a server
+type recordingHook struct {
+	commands [][]any
+}
+
+func (h *recordingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, fmt.Errorf("unexpected dial to %s", addr)
+	}
+}
+
+func (h *recordingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		h.commands = append(h.commands, cmd.Args())
+		return nil
+	}
+}
+
+func (h *recordingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		for _, cmd := range cmds {
+			h.commands = append(h.commands, cmd.Args())
+		}
+		return nil
+	}
+}
+
+func TestSlackCommandHandler_ShortcutPayloads(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	setSigningSecrets(nil)
+	origMode, origInteractive, origShortcut := redisMode, interactiveChannel, shortcutChannel
+	t.Cleanup(func() { redisMode, interactiveChannel, shortcutChannel = origMode, origInteractive, origShortcut })
+	redisMode = redisModeStream
+	interactiveChannel = "interactions"
+	shortcutChannel = "shortcuts"
+	backends = []string{backendRedis}
+	redisConnected.Store(true)
+
+	tests := []struct {
+		name            string
+		payload         string
+		expectedChannel string
+		expectedCommand string
+	}{
+		{
+			"global shortcut",
+			`{"type":"shortcut","callback_id":"open_ticket","trigger_id":"123.456","team":{"id":"T1"},"user":{"id":"U1","username":"steve"}}`,
+			"shortcuts", "open_ticket",
+		},
+		{
+			"message shortcut",
+			`{"type":"message_action","callback_id":"save_message","trigger_id":"123.456","team":{"id":"T1"},"channel":{"id":"C1"},"user":{"id":"U1","username":"steve"},"message":{"ts":"1700000000.000100","text":"hello"}}`,
+			"shortcuts", "save_message",
+		},
+		{
+			"block action",
+			`{"type":"block_actions","team":{"id":"T1"},"user":{"id":"U1","username":"steve"},"actions":[{"action_id":"approve"}]}`,
+			"interactions", "block_actions",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook := &recordingHook{}
+			client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1"})
+			defer client.Close()
+			client.AddHook(hook)
+			setRedisClient(client)
+
+			w := httptest.NewRecorder()
+			slackCommandHandler(w, interactiveRequest(tt.payload))
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d", w.Code)
+			}
+			if len(hook.commands) != 1 {
+				t.Fatalf("expected one Redis command, got %v", hook.commands)
+			}
+			args := hook.commands[0]
+			if args[0] != "xadd" || args[1] != tt.expectedChannel {
+				t.Errorf("expected XADD to %s, got %v", tt.expectedChannel, args[:2])
+			}
+			values := make(map[string]string)
+			for i := 3; i+1 < len(args); i += 2 {
+				values[fmt.Sprint(args[i])] = fmt.Sprintf("%s", args[i+1])
+			}
+			if values["command"] != tt.expectedCommand {
+				t.Errorf("expected command field %q, got %q", tt.expectedCommand, values["command"])
+			}
+			if values["payload"] != tt.payload {
+				t.Errorf("expected the payload to be published unchanged, got %s", values["payload"])
+			}
+		})
+	}
+}
+
+func TestSlackCommandHandler_InteractivePayloadRejected(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	setSigningSecrets(nil)
+
+	tests := []struct {
+		name     string
+		payload  string
+		token    string
+		expected int
+	}{
+		{"invalid JSON", `{"type":`, "", http.StatusBadRequest},
+		{"wrong token", `{"type":"block_actions","token":"wrong"}`, "expected", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verificationToken = []byte(tt.token)
+			if tt.token == "" {
+				verificationToken = nil
+			}
+			w := httptest.NewRecorder()
+			slackCommandHandler(w, interactiveRequest(tt.payload))
+			if w.Code != tt.expected {
+				t.Errorf("expected %d, got %d", tt.expected, w.Code)
+			}
+		})
+	}
+}
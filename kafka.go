@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	// kafkaPublishTimeout bounds each Kafka write
+	kafkaPublishTimeout = 5 * time.Second
+
+	// kafkaBatchTimeout is how long the writer waits to fill a batch. Writes are
+	// synchronous, so this is kept short to avoid delaying Slack's acknowledgement.
+	kafkaBatchTimeout = 10 * time.Millisecond
+)
+
+// kafkaPublisher writes each command to a Kafka topic, keyed by partition key
+// so commands for the same team or channel stay in order
+type kafkaPublisher struct {
+	writer *kafka.Writer
+}
+
+func newKafkaPublisher(brokers []string, topic string) *kafkaPublisher {
+	return &kafkaPublisher{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireOne,
+			BatchTimeout: kafkaBatchTimeout,
+		},
+	}
+}
+
+func (p *kafkaPublisher) Publish(ctx context.Context, key string, payload []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, kafkaPublishTimeout)
+	defer cancel()
+	return p.writer.WriteMessages(ctx, kafka.Message{Key: []byte(key), Value: payload})
+}
+
+// Close flushes any pending messages and closes the writer
+func (p *kafkaPublisher) Close() error {
+	return p.writer.Close()
+}
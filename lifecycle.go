@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Lifecycle events published to the control channel
+const (
+	lifecycleStarted  = "relay_started"
+	lifecycleStopping = "relay_stopping"
+
+	// defaultControlChannel receives lifecycle events when REDIS_CONTROL_CHANNEL is unset
+	defaultControlChannel = "slack-relay-control"
+)
+
+// emitLifecycleEvents publishes a control message to controlChannel when the
+// relay has started and when it begins shutting down, from EMIT_LIFECYCLE_EVENTS
+var emitLifecycleEvents bool
+var controlChannel = defaultControlChannel
+
+// LifecycleEvent is the control message published for a relay lifecycle change
+type LifecycleEvent struct {
+	Event  string    `json:"event"`
+	Source string    `json:"source"`
+	Time   Timestamp `json:"time"`
+}
+
+// publishLifecycleEvent announces a lifecycle change on the control channel,
+// using REDIS_MODE like commands do. Failures are only logged: an event is a
+// courtesy to consumers and must not hold up startup or shutdown.
+func publishLifecycleEvent(ctx context.Context, event string) {
+	if !emitLifecycleEvents {
+		return
+	}
+	client := getRedisClient()
+	if client == nil || !redisConnected.Load() {
+		logWarn("Redis unavailable, skipped %s event", event)
+		return
+	}
+	payload, err := json.Marshal(LifecycleEvent{Event: event, Source: relaySource, Time: Timestamp(time.Now())})
+	if err != nil {
+		logError("Error encoding %s event: %v", event, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, redisPublishTimeout)
+	defer cancel()
+	var cmd redis.Cmder
+	switch redisMode {
+	case redisModeStream:
+		cmd = client.XAdd(ctx, &redis.XAddArgs{Stream: controlChannel, Values: map[string]interface{}{"payload": payload, "event": event}})
+	case redisModeList:
+		cmd = client.LPush(ctx, controlChannel, payload)
+	default:
+		cmd = client.Publish(ctx, controlChannel, payload)
+	}
+	if err := cmd.Err(); err != nil {
+		logWarn("Error publishing %s event to %s: %v", event, controlChannel, err)
+		return
+	}
+	logInfo("Published %s event to %s", event, controlChannel)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPublishLifecycleEvent(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	origEmit := emitLifecycleEvents
+	t.Cleanup(func() { emitLifecycleEvents = origEmit })
+	f := newFakeRedis(t, 0)
+	useFakeRedis(t, f)
+
+	emitLifecycleEvents = false
+	publishLifecycleEvent(context.Background(), lifecycleStarted)
+	if got := f.commands.Load(); got != 0 {
+		t.Errorf("expected no event while disabled, got %d commands", got)
+	}
+
+	emitLifecycleEvents = true
+	publishLifecycleEvent(context.Background(), lifecycleStarted)
+	publishLifecycleEvent(context.Background(), lifecycleStopping)
+	if got := f.commands.Load(); got != 2 {
+		t.Errorf("expected 2 events, got %d commands", got)
+	}
+
+	// A disconnected Redis skips the event rather than blocking
+	redisConnected.Store(false)
+	start := time.Now()
+	publishLifecycleEvent(context.Background(), lifecycleStopping)
+	if got := f.commands.Load(); got != 2 || time.Since(start) > time.Second {
+		t.Errorf("expected the event to be skipped promptly, got %d commands", got)
+	}
+}
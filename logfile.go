@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+const (
+	// defaultLogFileMaxMB is the size at which LOG_FILE is rotated when LOG_FILE_MAX_MB is unset
+	defaultLogFileMaxMB = 100
+
+	// defaultLogFileBackups is the number of rotated files kept when LOG_FILE_BACKUPS is unset
+	defaultLogFileBackups = 3
+)
+
+// rotatingFile is a log file that is rotated once it reaches maxBytes, keeping
+// up to backups old files as path.1 (newest) to path.N. It can also be
+// reopened after an external tool such as logrotate has moved it.
+type rotatingFile struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+	backups  int
+	file     *os.File
+	size     int64
+}
+
+// openRotatingFile opens path for appending. maxBytes of 0 disables rotation.
+func openRotatingFile(path string, maxBytes int64, backups int) (*rotatingFile, error) {
+	f := &rotatingFile{path: path, maxBytes: maxBytes, backups: backups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.maxBytes > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxBytes {
+		if err := f.rotate(); err != nil {
+			// Keep writing to the current file rather than losing entries
+			fmt.Fprintf(os.Stderr, "[ERROR] Could not rotate %s: %v\n", f.path, err)
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the backups along, moves the current file to path.1 and starts a new one
+func (f *rotatingFile) rotate() error {
+	f.file.Close()
+
+	var err error
+	if f.backups > 0 {
+		// The oldest backup is overwritten by the rename chain
+		for i := f.backups - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+		}
+		err = os.Rename(f.path, f.path+".1")
+	} else {
+		err = os.Truncate(f.path, 0)
+	}
+
+	// Reopen even if the rename failed so logging continues
+	if openErr := f.open(); openErr != nil {
+		return openErr
+	}
+	return err
+}
+
+// reopen closes and reopens the file at path, for use on SIGHUP after logrotate
+// has moved the old file away
+func (f *rotatingFile) reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	old := f.file
+	if err := f.open(); err != nil {
+		return err
+	}
+	return old.Close()
+}
+
+// Sync commits the current file's contents to disk
+func (f *rotatingFile) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Sync()
+}
+
+// Close closes the underlying file
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
+
+// reopenOnSIGHUP reopens the log file each time the process receives SIGHUP,
+// the signal logrotate sends after moving a file away
+func reopenOnSIGHUP(f *rotatingFile) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		if err := f.reopen(); err != nil {
+			logError("Could not reopen log file %s: %v", f.path, err)
+			continue
+		}
+		logInfo("Reopened log file %s", f.path)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestRotatingFile_RotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "relay.log")
+	f, err := openRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if got := readFile(t, path); got != "fourth\n" {
+		t.Errorf("expected current file to hold the newest entry, got %q", got)
+	}
+	if got := readFile(t, path+".1"); got != "third\n" {
+		t.Errorf("expected newest backup in .1, got %q", got)
+	}
+	if got := readFile(t, path+".2"); got != "second\n" {
+		t.Errorf("expected older backup in .2, got %q", got)
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Error("expected no more than 2 backups to be kept")
+	}
+}
+
+func TestRotatingFile_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "relay.log")
+	f, err := openRotatingFile(path, 0, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+
+	f.Write([]byte("before\n"))
+	// Simulate logrotate moving the file away before sending SIGHUP
+	if err := os.Rename(path, filepath.Join(dir, "relay.log.old")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.reopen(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f.Write([]byte("after\n"))
+
+	if got := readFile(t, path); got != "after\n" {
+		t.Errorf("expected new entries in the reopened file, got %q", got)
+	}
+	if got := readFile(t, filepath.Join(dir, "relay.log.old")); got != "before\n" {
+		t.Errorf("expected old entries to stay in the moved file, got %q", got)
+	}
+}
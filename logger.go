@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"strings"
+)
+
+// LogLevel represents the logging level
+type LogLevel int
+
+const (
+	DEBUG LogLevel = iota
+	INFO
+	WARN
+	ERROR
+)
+
+// Log output formats selectable via LOG_FORMAT
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// String returns the upper-case name of the level
+func (l LogLevel) String() string {
+	switch l {
+	case DEBUG:
+		return "DEBUG"
+	case WARN:
+		return "WARN"
+	case ERROR:
+		return "ERROR"
+	default:
+		return "INFO"
+	}
+}
+
+// logger writes a single log entry. fields are alternating key/value pairs.
+type logger interface {
+	log(level LogLevel, msg string, fields []any)
+}
+
+var activeLogger logger = textLogger{out: log.Default()}
+
+// textLogger writes entries as "[LEVEL] msg key=value ..." lines
+type textLogger struct {
+	out *log.Logger
+}
+
+func (t textLogger) log(level LogLevel, msg string, fields []any) {
+	var b strings.Builder
+	b.WriteString("[" + level.String() + "] " + msg)
+	for i := 0; i+1 < len(fields); i += 2 {
+		fmt.Fprintf(&b, " %v=%v", fields[i], fields[i+1])
+	}
+	t.out.Print(b.String())
+}
+
+// jsonLogger writes entries as one JSON object per line with level, msg, ts
+// and any contextual fields
+type jsonLogger struct {
+	out *slog.Logger
+}
+
+func newJSONLogger(w io.Writer) jsonLogger {
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		// Filtering is done against currentLogLevel before entries reach the logger
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				a.Key = "ts"
+			}
+			return a
+		},
+	})
+	return jsonLogger{out: slog.New(handler)}
+}
+
+func (j jsonLogger) log(level LogLevel, msg string, fields []any) {
+	j.out.Log(context.Background(), slogLevel(level), msg, fields...)
+}
+
+// slogLevel maps a LogLevel to the equivalent slog level
+func slogLevel(level LogLevel) slog.Level {
+	switch level {
+	case DEBUG:
+		return slog.LevelDebug
+	case WARN:
+		return slog.LevelWarn
+	case ERROR:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// setLogOutput directs all log entries, including those of the standard
+// library's default logger, to w in the given format
+func setLogOutput(w io.Writer, format string) {
+	log.SetOutput(w)
+	if format == logFormatJSON {
+		activeLogger = newJSONLogger(w)
+	} else {
+		activeLogger = textLogger{out: log.Default()}
+	}
+}
+
+// parseLogFormat converts a string to a log format, defaulting to text
+func parseLogFormat(format string) string {
+	switch strings.ToLower(format) {
+	case "", logFormatText:
+		return logFormatText
+	case logFormatJSON:
+		return logFormatJSON
+	default:
+		logWarn("Unknown LOG_FORMAT %q, using %s", format, logFormatText)
+		return logFormatText
+	}
+}
+
+// logAt logs a formatted message if level is enabled
+func logAt(level LogLevel, format string, v ...interface{}) {
+	if currentLogLevel <= level {
+		activeLogger.log(level, fmt.Sprintf(format, v...), nil)
+	}
+}
+
+// logFields logs msg with structured key/value pairs if level is enabled
+func logFields(level LogLevel, msg string, fields ...any) {
+	if currentLogLevel <= level {
+		activeLogger.log(level, msg, fields)
+	}
+}
+
+// logRequest logs a formatted message tagged with the request's correlation ID
+func logRequest(level LogLevel, requestID string, format string, v ...interface{}) {
+	if currentLogLevel <= level {
+		activeLogger.log(level, fmt.Sprintf(format, v...), []any{"request_id", requestID})
+	}
+}
+
+// logDebug logs a message at DEBUG level
+func logDebug(format string, v ...interface{}) {
+	logAt(DEBUG, format, v...)
+}
+
+// logInfo logs a message at INFO level
+func logInfo(format string, v ...interface{}) {
+	logAt(INFO, format, v...)
+}
+
+// logWarn logs a message at WARN level
+func logWarn(format string, v ...interface{}) {
+	logAt(WARN, format, v...)
+}
+
+// logError logs a message at ERROR level
+func logError(format string, v ...interface{}) {
+	logAt(ERROR, format, v...)
+}
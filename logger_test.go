@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestJSONLogger(t *testing.T) {
+	var buf bytes.Buffer
+	newJSONLogger(&buf).log(WARN, "Redis unavailable", []any{"command", "/deploy"})
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("expected a JSON log entry, got %q: %v", buf.String(), err)
+	}
+	if entry["level"] != "WARN" {
+		t.Errorf("expected level WARN, got %v", entry["level"])
+	}
+	if entry["msg"] != "Redis unavailable" {
+		t.Errorf("expected msg, got %v", entry["msg"])
+	}
+	if _, ok := entry["ts"]; !ok {
+		t.Error("expected ts field")
+	}
+	if entry["command"] != "/deploy" {
+		t.Errorf("expected command field, got %v", entry["command"])
+	}
+}
+
+func TestTextLogger(t *testing.T) {
+	var buf bytes.Buffer
+	textLogger{out: log.New(&buf, "", 0)}.log(INFO, "Received command", []any{"command", "/deploy"})
+
+	if got := strings.TrimSpace(buf.String()); got != "[INFO] Received command command=/deploy" {
+		t.Errorf("unexpected text log line: %q", got)
+	}
+}
+
+func TestLogFields_RespectsLevel(t *testing.T) {
+	origLogger, origLevel := activeLogger, currentLogLevel
+	t.Cleanup(func() { activeLogger, currentLogLevel = origLogger, origLevel })
+
+	var buf bytes.Buffer
+	activeLogger = newJSONLogger(&buf)
+	currentLogLevel = WARN
+
+	logFields(INFO, "suppressed")
+	logInfo("suppressed too")
+	if buf.Len() != 0 {
+		t.Errorf("expected INFO entries to be suppressed at WARN, got %q", buf.String())
+	}
+	logError("kept %d", 1)
+	if !strings.Contains(buf.String(), `"msg":"kept 1"`) {
+		t.Errorf("expected ERROR entry, got %q", buf.String())
+	}
+}
+
+func TestParseLogFormat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"", logFormatText},
+		{"text", logFormatText},
+		{"json", logFormatJSON},
+		{"JSON", logFormatJSON},
+		{"xml", logFormatText},
+	}
+	for _, tt := range tests {
+		if got := parseLogFormat(tt.input); got != tt.expected {
+			t.Errorf("parseLogFormat(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
@@ -4,35 +4,105 @@ import (
 	"context"
 	"crypto/hmac"
 	"crypto/sha256"
+	"crypto/tls"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
-	"log"
+	"maps"
+	"mime"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
+	"runtime"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"text/template"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
 )
 
-// LogLevel represents the logging level
-type LogLevel int
+const (
+	// slackTimestampToleranceSeconds is the default maximum age of a Slack request timestamp
+	// Slack recommends rejecting requests older than 5 minutes to prevent replay attacks
+	slackTimestampToleranceSeconds = 300
+
+	// healthCheckTimeout bounds the Redis ping performed by the /health endpoint
+	healthCheckTimeout = 2 * time.Second
+
+	// defaultShutdownGracePeriod stays within Kubernetes' default 30s termination grace period
+	defaultShutdownGracePeriod = 25 * time.Second
+
+	// defaultResponseTemplate is the acknowledgement sent to Slack when RESPONSE_TEMPLATE is unset
+	defaultResponseTemplate = "Slash command `{{.Command}}` received 🎉"
+
+	// defaultAsyncResponseTemplate replaces an ASYNC_RESPONSE_TEMPLATE that fails to render
+	defaultAsyncResponseTemplate = "⏳ `{{.Command}}` is being processed"
+
+	// defaultDenylistMessage is shown to users of a denylisted command when COMMAND_DENYLIST_MESSAGE is unset
+	defaultDenylistMessage = "This command is currently disabled."
+
+	// defaultSignatureFailureMessage is returned for rejected signatures when
+	// DEBUG_SIGNATURE_FAILURES is enabled and SIGNATURE_FAILURE_MESSAGE is unset
+	defaultSignatureFailureMessage = "Invalid signature: check that the relay's signing secret matches the Slack app's"
+
+	// concurrencyLimitMessage is shown to users when MAX_CONCURRENT commands are already in flight
+	concurrencyLimitMessage = "The relay is busy right now. Please try your command again in a moment."
+
+	// Default HTTP server timeouts, so slow clients can't hold connections open
+	// indefinitely. The write timeout leaves room for REQUEST_TIMEOUT's 503 reply.
+	defaultReadHeaderTimeout = 5 * time.Second
+	defaultReadTimeout       = 10 * time.Second
+	defaultWriteTimeout      = 15 * time.Second
+	defaultIdleTimeout       = 60 * time.Second
+
+	// defaultRequestTimeout bounds command handling end-to-end when REQUEST_TIMEOUT is unset
+	defaultRequestTimeout = 10 * time.Second
+
+	// defaultMaxBodyBytes is far larger than any legitimate Slack command payload
+	defaultMaxBodyBytes = 64 << 10
+
+	// defaultRedisPublishTimeout bounds each Redis publish when REDIS_PUBLISH_TIMEOUT is unset
+	defaultRedisPublishTimeout = 5 * time.Second
+
+	// defaultRedisHealthCheckInterval is how often Redis is pinged when REDIS_HEALTHCHECK_INTERVAL is unset
+	defaultRedisHealthCheckInterval = 15 * time.Second
+)
 
+// Redis delivery modes selectable via REDIS_MODE
 const (
-	DEBUG LogLevel = iota
-	INFO
-	WARN
-	ERROR
+	redisModePubSub = "pubsub"
+	redisModeStream = "stream"
+	redisModeList   = "list"
 )
 
+// Slack response types selectable via RESPONSE_TYPE
 const (
-	// slackTimestampToleranceSeconds is the maximum age of a Slack request timestamp
-	// Slack recommends rejecting requests older than 5 minutes to prevent replay attacks
-	slackTimestampToleranceSeconds = 300
+	responseTypeEphemeral = "ephemeral"
+	responseTypeInChannel = "in_channel"
+	// responseTypeEmpty acknowledges with an empty body, which Slack treats as a silent ack
+	responseTypeEmpty = "empty"
+)
+
+// Timestamp formats selectable via TIMESTAMP_FORMAT
+const (
+	timestampFormatRFC3339    = "rfc3339"
+	timestampFormatUnixMillis = "unix_millis"
 )
 
 // SlackCommand represents a parsed Slack command request
@@ -53,10 +123,91 @@ type SlackCommand struct {
 	EnterpriseName string `json:"enterprise_name,omitempty"`
 }
 
-var signingSecret []byte
-var redisClient *redis.Client
+// SlackResponse is the JSON acknowledgement returned to Slack for a command
+type SlackResponse struct {
+	ResponseType string `json:"response_type"`
+	Text         string `json:"text"`
+}
+
+var verificationToken []byte
+
+// timestampPastToleranceSeconds and timestampFutureToleranceSeconds bound how
+// far a request timestamp may lag or lead the server clock. Future-dated
+// requests are almost always tampered with, so they can be bounded separately.
+var timestampPastToleranceSeconds int64 = slackTimestampToleranceSeconds
+var timestampFutureToleranceSeconds int64 = slackTimestampToleranceSeconds
 var currentLogLevel LogLevel = INFO
 var redisChannel string
+var timestampFormat = timestampFormatRFC3339
+var commandChannels map[string]string
+var redisMode = redisModePubSub
+var redisPublishTimeout = defaultRedisPublishTimeout
+var redisHealthCheckInterval = defaultRedisHealthCheckInterval
+var maxBodyBytes int64 = defaultMaxBodyBytes
+var responseType = responseTypeEphemeral
+var teamAllowlist map[string]bool
+var commandAllowlist map[string]bool
+var commandDenylist map[string]bool
+var denylistMessage = defaultDenylistMessage
+var debugSignatureFailures bool
+var signatureFailureMessage = defaultSignatureFailureMessage
+var responseTemplate = defaultResponse
+
+// asyncResponseTemplate is ASYNC_RESPONSE_TEMPLATE, nil unless configured
+var asyncResponseTemplate *template.Template
+
+// asyncAcknowledgement acknowledges commands handed to the worker pool with
+// asyncResponseTemplate; set once at startup by selectResponseTemplate
+var asyncAcknowledgement bool
+
+// The built-in acknowledgements, also used when a configured template fails to render
+var (
+	defaultResponse      = template.Must(template.New("response").Parse(defaultResponseTemplate))
+	defaultAsyncResponse = template.Must(template.New("async_response").Parse(defaultAsyncResponseTemplate))
+)
+var commandResponses map[string]*template.Template
+
+// allowGetPing answers GET requests to the command path with a static 200
+var allowGetPing bool
+
+// routeByEnterprise publishes Enterprise Grid commands to a per-org channel
+var routeByEnterprise bool
+
+// redisMetrics counts each command published to Redis in per-command keys
+var redisMetrics bool
+
+// dryRun handles commands fully but logs them instead of publishing, for safely
+// onboarding a new workspace
+var dryRun bool
+
+// redisClient is read by every handler and publish goroutine, so it is only
+// accessed atomically through getRedisClient and setRedisClient
+var redisClient atomic.Pointer[redis.UniversalClient]
+
+// getRedisClient returns the Redis client, or nil if Redis is not configured
+func getRedisClient() redis.UniversalClient {
+	if client := redisClient.Load(); client != nil {
+		return *client
+	}
+	return nil
+}
+
+// setRedisClient replaces the Redis client; nil disables Redis
+func setRedisClient(client redis.UniversalClient) {
+	if client == nil {
+		redisClient.Store(nil)
+		return
+	}
+	redisClient.Store(&client)
+}
+
+// redisConnected is set once Redis has answered a ping. redisClient is created
+// once at startup and kept while the relay reconnects in the background, so
+// until then publishes fail fast and wait in the retry queue.
+var redisConnected atomic.Bool
+
+// ready is set once startup has finished loading the secret and connecting to Redis
+var ready atomic.Bool
 
 // parseLogLevel converts a string to LogLevel
 func parseLogLevel(level string) LogLevel {
@@ -74,36 +225,153 @@ func parseLogLevel(level string) LogLevel {
 	}
 }
 
-// logDebug logs a message at DEBUG level
-func logDebug(format string, v ...interface{}) {
-	if currentLogLevel <= DEBUG {
-		log.Printf("[DEBUG] "+format, v...)
+// parseRedisMode converts a string to a Redis delivery mode, defaulting to pub/sub
+func parseRedisMode(mode string) string {
+	switch strings.ToLower(mode) {
+	case "", redisModePubSub:
+		return redisModePubSub
+	case redisModeStream:
+		return redisModeStream
+	case redisModeList:
+		return redisModeList
+	default:
+		logWarn("Unknown REDIS_MODE %q, using %s", mode, redisModePubSub)
+		return redisModePubSub
+	}
+}
+
+// parseResponseType converts a string to a Slack response type, defaulting to ephemeral
+func parseResponseType(value string) string {
+	switch strings.ToLower(value) {
+	case "", responseTypeEphemeral:
+		return responseTypeEphemeral
+	case responseTypeInChannel:
+		return responseTypeInChannel
+	case responseTypeEmpty:
+		return responseTypeEmpty
+	default:
+		logWarn("Unknown RESPONSE_TYPE %q, using %s", value, responseTypeEphemeral)
+		return responseTypeEphemeral
+	}
+}
+
+// parseTimestampFormat converts a string to a timestamp format, defaulting to RFC 3339
+func parseTimestampFormat(value string) string {
+	switch strings.ToLower(value) {
+	case "", timestampFormatRFC3339:
+		return timestampFormatRFC3339
+	case timestampFormatUnixMillis:
+		return timestampFormatUnixMillis
+	default:
+		logWarn("Unknown TIMESTAMP_FORMAT %q, using %s", value, timestampFormatRFC3339)
+		return timestampFormatRFC3339
+	}
+}
+
+// getEnvInt reads an integer from the named environment variable, returning
+// defaultValue when it is unset or not a valid integer
+func getEnvInt(name string, defaultValue int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		logWarn("Invalid value for %s: %q is not an integer, using default %d", name, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// splitList splits a comma-separated value into its trimmed, non-empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// toSet converts a list of items into a set for membership checks.
+// An empty list yields a nil set.
+func toSet(items []string) map[string]bool {
+	if len(items) == 0 {
+		return nil
 	}
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[item] = true
+	}
+	return set
+}
+
+// getEnvFloat reads a floating-point number from the named environment variable,
+// returning defaultValue when it is unset or not a valid number
+func getEnvFloat(name string, defaultValue float64) float64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		logWarn("Invalid value for %s: %q is not a number, using default %g", name, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
 }
 
-// logInfo logs a message at INFO level
-func logInfo(format string, v ...interface{}) {
-	if currentLogLevel <= INFO {
-		log.Printf("[INFO] "+format, v...)
+// getEnvDuration reads a duration such as "2s" or "500ms" from the named environment
+// variable, returning defaultValue when it is unset, unparseable or not positive
+func getEnvDuration(name string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
 	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed <= 0 {
+		logWarn("Invalid value for %s: %q is not a positive duration, using default %s", name, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
 }
 
-// logWarn logs a message at WARN level
-func logWarn(format string, v ...interface{}) {
-	if currentLogLevel <= WARN {
-		log.Printf("[WARN] "+format, v...)
+// getEnvOptionalDuration is getEnvDuration for settings where 0 disables the
+// feature, so only unparseable or negative values fall back to defaultValue
+func getEnvOptionalDuration(name string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed < 0 {
+		logWarn("Invalid value for %s: %q is not a non-negative duration, using default %s", name, value, defaultValue)
+		return defaultValue
 	}
+	return parsed
 }
 
-// logError logs a message at ERROR level
-func logError(format string, v ...interface{}) {
-	if currentLogLevel <= ERROR {
-		log.Printf("[ERROR] "+format, v...)
+// getEnvBool reads a boolean from the named environment variable, returning
+// defaultValue when it is unset or not a valid boolean
+func getEnvBool(name string, defaultValue bool) bool {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
 	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		logWarn("Invalid value for %s: %q is not a boolean, using default %t", name, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
 }
 
-func verifySlackSignature(secret []byte, body []byte, timestamp string, signature string) bool {
-	if len(secret) == 0 {
+// verifySlackSignature checks the request signature against each secret in turn
+// and accepts it if any of them match
+func verifySlackSignature(secrets [][]byte, body []byte, timestamp string, signature string) bool {
+	if len(secrets) == 0 {
 		// No secret configured, skip verification
 		return true
 	}
@@ -118,9 +386,13 @@ func verifySlackSignature(secret []byte, body []byte, timestamp string, signatur
 		return false
 	}
 
-	now := time.Now().Unix()
-	if absInt64(now-ts) > slackTimestampToleranceSeconds {
-		logWarn("Request timestamp too old or too far in the future")
+	age := time.Now().Unix() - ts
+	if age > timestampPastToleranceSeconds {
+		logWarn("Request timestamp %ds in the past exceeds the %ds past tolerance", age, timestampPastToleranceSeconds)
+		return false
+	}
+	if -age > timestampFutureToleranceSeconds {
+		logWarn("Request timestamp %ds in the future exceeds the %ds future tolerance", -age, timestampFutureToleranceSeconds)
 		return false
 	}
 
@@ -129,26 +401,95 @@ func verifySlackSignature(secret []byte, body []byte, timestamp string, signatur
 		return false
 	}
 
-	signatureHash := strings.TrimPrefix(signature, "v0=")
+	// Compare raw MACs rather than hex strings; malformed hex can never match
+	signatureMAC, err := hex.DecodeString(strings.TrimPrefix(signature, "v0="))
+	if err != nil || len(signatureMAC) != sha256.Size {
+		return false
+	}
 
 	// Compute expected signature: v0:<timestamp>:<body>
 	baseString := fmt.Sprintf("v0:%s:%s", timestamp, string(body))
-	mac := hmac.New(sha256.New, secret)
-	mac.Write([]byte(baseString))
-	expectedMAC := mac.Sum(nil)
-	expectedSignature := hex.EncodeToString(expectedMAC)
+	for _, secret := range secrets {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(baseString))
+
+		if hmac.Equal(signatureMAC, mac.Sum(nil)) {
+			return true
+		}
+	}
+	return false
+}
+
+// logSignatureFailure logs who sent a request that failed verification, parsing
+// the unverified body leniently, to help diagnose a misconfigured signing secret.
+// The values are attacker-controlled, so this is only done with DEBUG_SIGNATURE_FAILURES.
+func logSignatureFailure(requestID string, body []byte, timestamp string) {
+	// ParseQuery keeps the pairs it could parse when it returns an error
+	values, _ := url.ParseQuery(string(body))
+	teamID, appID := requestIdentity(body)
+	logFields(WARN, "Invalid Slack signature",
+		"request_id", requestID,
+		"team_id", teamID,
+		"api_app_id", appID,
+		"command", values.Get("command"),
+		"timestamp", timestamp,
+		"secrets_configured", len(getSigningSecrets()),
+	)
+}
+
+// unverifiedWarnings limits the per-request warning about commands accepted
+// without a signing secret to one a minute
+var unverifiedWarnings = rate.NewLimiter(rate.Every(time.Minute), 1)
+
+// warnUnverified logs that a command was accepted without signature
+// verification, at most once a minute. Dry runs and requests from the local
+// host are expected to be unverified and are not reported.
+func warnUnverified(r *http.Request, requestID string, command SlackCommand) {
+	if dryRun || isLoopback(r.RemoteAddr) || !unverifiedWarnings.Allow() {
+		return
+	}
+	logFields(WARN, "Accepted command without signature verification: no Slack signing secret is configured",
+		"request_id", requestID,
+		"team_id", command.TeamID,
+		"command", command.Command,
+		"remote_addr", r.RemoteAddr,
+	)
+}
 
-	return hmac.Equal([]byte(signatureHash), []byte(expectedSignature))
+// verifyToken compares the request's verification token with the expected one in
+// constant time. An empty expected token skips the check.
+func verifyToken(expected []byte, token string) bool {
+	if len(expected) == 0 {
+		return true
+	}
+	return hmac.Equal(expected, []byte(token))
 }
 
-func absInt64(x int64) int64 {
-	if x < 0 {
-		return -x
+// missingRequiredFields returns the names of the form fields every Slack command
+// must carry that are empty in command
+func missingRequiredFields(command SlackCommand) []string {
+	var missing []string
+	if command.TeamID == "" {
+		missing = append(missing, "team_id")
+	}
+	if command.Command == "" {
+		missing = append(missing, "command")
 	}
-	return x
+	return missing
 }
 
 func slackCommandHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() {
+		handlerDuration.Observe(time.Since(start).Seconds())
+	}()
+
+	// Uptime checkers that only know GET get a static reply, when ALLOW_GET_PING is enabled
+	if r.Method == http.MethodGet && allowGetPing {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("ok"))
+		return
+	}
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -156,8 +497,26 @@ func slackCommandHandler(w http.ResponseWriter, r *http.Request) {
 
 	defer r.Body.Close()
 
+	// Correlate this command across logs, the published payload and the response
+	requestID := requestIDFor(r)
+	w.Header().Set(requestIDHeader, requestID)
+
+	// Continue an inbound trace if there is one; the span is a no-op unless OTEL_ENABLED
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := tracer().Start(ctx, "slack.command", trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
+	span.SetAttributes(attribute.String("slack.request_id", requestID))
+
+	// Bound the body before reading it; signature verification needs the whole body in memory
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			logRequest(WARN, requestID, "Rejected request body larger than %d bytes", maxBytesErr.Limit)
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
 		http.Error(w, "Error reading request body", http.StatusBadRequest)
 		return
 	}
@@ -165,21 +524,240 @@ func slackCommandHandler(w http.ResponseWriter, r *http.Request) {
 	// Verify Slack request signature
 	timestamp := r.Header.Get("X-Slack-Request-Timestamp")
 	signature := r.Header.Get("X-Slack-Signature")
-	if !verifySlackSignature(signingSecret, body, timestamp, signature) {
-		logWarn("Invalid Slack signature")
+	secrets, ok := signingSecretsFor(body)
+	signatureValid := ok && verifySlackSignature(secrets, body, timestamp, signature)
+	if !signatureValid && fromSocketMode(r.Context()) {
+		// Slack authenticated the Socket Mode connection with the app token
+		signatureValid = true
+	}
+	if !signatureValid && isTrustedIP(r.RemoteAddr) {
+		logRequest(WARN, requestID, "SKIPPING Slack signature verification for trusted IP %s. TRUSTED_IPS is for testing only.", r.RemoteAddr)
+		signatureValid = true
+	}
+	if !signatureValid {
+		if debugSignatureFailures {
+			logSignatureFailure(requestID, body, timestamp)
+			http.Error(w, signatureFailureMessage, http.StatusUnauthorized)
+			return
+		}
+		logRequest(WARN, requestID, "Invalid Slack signature")
 		http.Error(w, "Invalid signature", http.StatusUnauthorized)
 		return
 	}
 
-	// Parse URL-encoded form data from Slack command
-	values, err := url.ParseQuery(string(body))
+	// The Events API handshakes with a signed JSON challenge before sending events
+	if handleURLVerification(w, requestID, body) {
+		return
+	}
+
+	// Hash the body exactly as received so consumers can audit what the relay was sent
+	bodyHash := sha256.Sum256(body)
+
+	var command SlackCommand
+	if isJSONContentType(r.Header.Get("Content-Type")) {
+		// API gateways sometimes convert Slack's form body to JSON; the
+		// signature above was still checked against the body as received
+		if err := json.Unmarshal(body, &command); err != nil {
+			logRequest(WARN, requestID, "Malformed JSON command: %v", err)
+			malformedRequests.WithLabelValues("parse_error").Inc()
+			http.Error(w, "Error parsing JSON body", http.StatusBadRequest)
+			return
+		}
+	} else {
+		// Parse URL-encoded form data from Slack command
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			logRequest(WARN, requestID, "Malformed form data: %v", err)
+			malformedRequests.WithLabelValues("parse_error").Inc()
+			http.Error(w, "Error parsing form data", http.StatusBadRequest)
+			return
+		}
+
+		// Interactive components post a JSON payload field instead of the command fields
+		if values.Has("payload") {
+			handleInteractivePayload(ctx, w, requestID, values.Get("payload"))
+			return
+		}
+		command = commandFromForm(values)
+	}
+
+	// Slack always sends these; their absence points at misrouted or probing traffic
+	if missing := missingRequiredFields(command); len(missing) > 0 {
+		logRequest(WARN, requestID, "Rejected request missing required fields: %s", strings.Join(missing, ", "))
+		malformedRequests.WithLabelValues("missing_fields").Inc()
+		http.Error(w, "Missing required fields: "+strings.Join(missing, ", "), http.StatusBadRequest)
+		return
+	}
+
+	if !signatureVerificationEnabled() && !fromSocketMode(r.Context()) {
+		warnUnverified(r, requestID, command)
+	}
+
+	envelope := newPublishEnvelope(command, requestID, time.Now())
+	envelope.BodySHA256 = hex.EncodeToString(bodyHash[:])
+	envelope.RetryNum, envelope.RetryReason = slackRetry(r)
+	span.SetAttributes(
+		attribute.String("slack.command", command.Command),
+		attribute.String("slack.team_id", command.TeamID),
+		attribute.String("slack.user_id", command.UserID),
+	)
+	// Downstream consumers continue the trace from the published payload
+	envelope.Trace = traceCarrier(ctx)
+
+	// Check the legacy verification token as a second factor, when configured
+	if !verifyToken(verificationToken, command.Token) {
+		logRequest(WARN, requestID, "Invalid Slack verification token for command %s from team %s", command.Command, command.TeamID)
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	logRequest(INFO, requestID, "Received Slack command: %s from user %s", command.Command, command.UserName)
+	commandsReceived.WithLabelValues(commandLabel(command.Command)).Inc()
+	statsReceived.Add(1)
+
+	// Reject commands from workspaces that aren't in the team allowlist, when one is configured
+	if teamAllowlist != nil && !teamAllowlist[command.TeamID] {
+		logRequest(WARN, requestID, "Rejected command %s from team not in allowlist: %s", command.Command, command.TeamID)
+		writeCommandError(w, http.StatusForbidden, command, "Team not allowed")
+		return
+	}
+
+	// Reject denylisted commands; the denylist takes precedence over the allowlist
+	if commandDenylist[command.Command] {
+		logRequest(INFO, requestID, "Rejected denylisted command: %s from user %s", command.Command, command.UserName)
+		writeEphemeralResponse(w, http.StatusOK, errorResponseText(command, denylistMessage))
+		return
+	}
+
+	// Reject commands that aren't in the allowlist, when one is configured
+	if commandAllowlist != nil && !commandAllowlist[command.Command] {
+		logRequest(WARN, requestID, "Rejected command not in allowlist: %s from user %s", command.Command, command.UserName)
+		writeCommandError(w, http.StatusForbidden, command, "Command not allowed")
+		return
+	}
+
+	// Reject commands used outside the channels they are allowed in, when an allowlist is configured
+	if !channelAllowed(command) {
+		logRequest(WARN, requestID, "Rejected command %s from channel not in allowlist: %s", command.Command, command.ChannelID)
+		writeEphemeralResponse(w, http.StatusOK, errorResponseText(command, defaultChannelNotAllowedMessage))
+		return
+	}
+
+	// Reject commands exceeding the per-user or per-team rate limit, when one is configured
+	if commandRateLimiter != nil && !commandRateLimiter.allow(rateLimitKeyFor(command)) {
+		logRequest(WARN, requestID, "Rate limited command %s for %s %s", command.Command, rateLimitKey, rateLimitKeyFor(command))
+		writeEphemeralResponse(w, http.StatusOK, errorResponseText(command, defaultRateLimitMessage))
+		return
+	}
+
+	// Direct POSTs can exceed Slack's own text limit; reject or truncate what would be published
+	if n, tooLong := textTooLong(command.Text); tooLong {
+		if textOverflow != textOverflowTruncate {
+			logRequest(WARN, requestID, "Rejected command %s with %d characters of text, limit %d", command.Command, n, maxTextLength)
+			writeEphemeralResponse(w, http.StatusOK, errorResponseText(command, textTooLongMessage(n)))
+			return
+		}
+		logRequest(WARN, requestID, "Truncated text of command %s from %d to %d characters", command.Command, n, maxTextLength)
+		command.Text = truncateText(command.Text, maxTextLength)
+		envelope.Command.Text = command.Text
+		envelope.Args = ParseArgs(command.Text)
+		envelope.TextTruncated = true
+	}
+
+	// Internal callers may choose the Redis channel, when ALLOW_CHANNEL_OVERRIDE is enabled
+	channelOverride, ok := requestChannelOverride(r)
+	if !ok {
+		logRequest(WARN, requestID, "Rejected %s %q not matching CHANNEL_OVERRIDE_PATTERN", channelOverrideHeader, r.Header.Get(channelOverrideHeader))
+		http.Error(w, "Channel override not allowed", http.StatusForbidden)
+		return
+	}
+	if channelOverride != "" {
+		logRequest(INFO, requestID, "Routing command %s to overridden Redis channel: %s", command.Command, channelOverride)
+	}
+
+	// Mask sensitive fields such as the verification token before they are logged or published
+	envelope.redact(redactFields)
+	envelope.normalizeCommand()
+
+	// Only log payload at DEBUG level
+	if currentLogLevel <= DEBUG {
+		jsonOutput, err := json.MarshalIndent(envelope, "", "  ")
+		if err != nil {
+			logRequest(ERROR, requestID, "Error formatting JSON: %v", err)
+			logRequest(DEBUG, requestID, "Raw payload: %s", string(body))
+		} else {
+			logRequest(DEBUG, requestID, "Slack command payload:\n%s", string(jsonOutput))
+		}
+	}
+
+	// Convert the envelope to JSON for publishing
+	jsonPayload, err := marshalEnvelope(envelope)
 	if err != nil {
-		http.Error(w, "Error parsing form data", http.StatusBadRequest)
+		logRequest(ERROR, requestID, "Error marshaling envelope to JSON: %v", err)
+		writeSlackResponse(w, renderResponse(command, false))
+		return
+	}
+
+	// Acknowledge redeliveries of a body already published so Slack stops retrying.
+	// If Redis can't be checked, the command is published rather than risk losing it.
+	var deliveryKey string
+	if dedupe && !dryRun {
+		duplicate, err := seenBefore(ctx, bodyHash)
+		if err != nil {
+			logRequest(WARN, requestID, "Could not check command %s for duplicates, publishing anyway: %v", command.Command, err)
+		} else if duplicate {
+			logRequest(INFO, requestID, "Dropped duplicate delivery of command %s (retry %d, %s)", command.Command, envelope.RetryNum, envelope.RetryReason)
+			duplicateDeliveries.Inc()
+			writeSlackResponse(w, renderResponse(command, false))
+			return
+		} else {
+			deliveryKey = dedupeKey(bodyHash)
+		}
+	}
+
+	// Let the user know the command is in progress while the consumer works on it
+	if useResponseURL && command.ResponseURL != "" {
+		postWorkingMessage(requestID, command)
+	}
+
+	if !dryRun {
+		streamEnvelope(envelope)
+		feedCommand(jsonPayload)
+	}
+
+	// Publish to the configured backends, handing off to the worker pool when enabled
+	var publishErr error
+	queued := false
+	if dryRun {
+		logRequest(INFO, requestID, "[DRY RUN] would publish to %s: %s", publishTarget(command, channelOverride), jsonPayload)
+	} else if activePublisher != nil {
+		// Backends see only the redacted fields, so masked values can't leak through keys or attributes
+		published := redactCommand(command, redactFields)
+		job := publishJob{key: partitionKeyFor(published), command: published, channel: channelOverride, requestID: requestID, payload: jsonPayload, span: span.SpanContext(), dedupeKey: deliveryKey}
+		if publishQueue == nil {
+			// The request context is cancelled if REQUEST_TIMEOUT expires mid-publish
+			publishErr = publishCommand(ctx, job)
+		} else if publishQueue.submit(job) {
+			queued = true
+		} else {
+			logRequest(WARN, requestID, "Publish queue full (%d commands) or draining, publishing synchronously", cap(publishQueue.jobs))
+			publishErr = publishCommand(ctx, job)
+		}
+	}
+
+	// The failed publish is retried in the background, so only tell the user
+	// when ERROR_RESPONSE_TEMPLATE asks for it
+	if publishErr != nil && errorResponseTemplate != nil {
+		writeEphemeralResponse(w, http.StatusOK, errorResponseText(command, publishFailureMessage))
 		return
 	}
+	// Only commands left to the workers are still being processed
+	writeSlackResponse(w, renderResponse(command, queued))
+}
 
-	// Convert to SlackCommand struct
-	command := SlackCommand{
+// commandFromForm converts the URL-encoded fields Slack posts into a SlackCommand
+func commandFromForm(values url.Values) SlackCommand {
+	return SlackCommand{
 		Token:          values.Get("token"),
 		TeamID:         values.Get("team_id"),
 		TeamDomain:     values.Get("team_domain"),
@@ -195,117 +773,1332 @@ func slackCommandHandler(w http.ResponseWriter, r *http.Request) {
 		EnterpriseID:   values.Get("enterprise_id"),
 		EnterpriseName: values.Get("enterprise_name"),
 	}
+}
+
+// isJSONContentType reports whether a Content-Type header names a JSON body,
+// e.g. "application/json; charset=utf-8"
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "application/json"
+}
 
-	logInfo("Received Slack command: %s from user %s", command.Command, command.UserName)
+// parseCommandResponses parses COMMAND_RESPONSES, a JSON object mapping command
+// names to acknowledgement templates such as {"/deploy":"Deploy of {{.Text}} queued"}
+func parseCommandResponses(value string) (map[string]*template.Template, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
 
-	// Only log payload at DEBUG level
-	if currentLogLevel <= DEBUG {
-		jsonOutput, err := json.MarshalIndent(command, "", "  ")
+	var raw map[string]string
+	if err := json.Unmarshal([]byte(value), &raw); err != nil {
+		return nil, fmt.Errorf("parsing JSON: %w", err)
+	}
+	templates := make(map[string]*template.Template, len(raw))
+	for command, text := range raw {
+		tmpl, err := template.New(command).Parse(text)
 		if err != nil {
-			logError("Error formatting JSON: %v", err)
-			logDebug("Raw payload: %s", string(body))
-		} else {
-			logDebug("Slack command payload:\n%s", string(jsonOutput))
+			return nil, fmt.Errorf("template for %s: %w", command, err)
 		}
+		templates[command] = tmpl
 	}
+	return templates, nil
+}
 
-	// Publish to Redis if client is configured
-	if redisClient != nil {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
+// selectResponseTemplate enables ASYNC_RESPONSE_TEMPLATE for queued commands
+// when it is configured and the worker pool is running, since Slack is then
+// answered before the command is published. Async publishing is on by default,
+// so without the template every command keeps RESPONSE_TEMPLATE. It runs once
+// at startup, after the publish mode is known.
+func selectResponseTemplate() {
+	asyncAcknowledgement = publishQueue != nil && asyncResponseTemplate != nil
+}
 
-		// Convert command to JSON for publishing
-		jsonPayload, err := json.Marshal(command)
-		if err != nil {
-			logError("Error marshaling command to JSON: %v", err)
-		} else {
-			err = redisClient.Publish(ctx, redisChannel, jsonPayload).Err()
-			if err != nil {
-				logError("Error publishing to Redis channel '%s': %v", redisChannel, err)
-				// Don't fail the request if Redis publish fails
-			} else {
-				logInfo("Published command to Redis channel: %s", redisChannel)
-			}
+// renderResponse renders the acknowledgement text for a command, using its
+// COMMAND_RESPONSES template if it has one. Otherwise queued commands use
+// ASYNC_RESPONSE_TEMPLATE when enabled and the rest RESPONSE_TEMPLATE.
+func renderResponse(command SlackCommand, queued bool) string {
+	tmpl, fallback := responseTemplate, defaultResponse
+	if queued && asyncAcknowledgement {
+		tmpl, fallback = asyncResponseTemplate, defaultAsyncResponse
+	}
+	if commandTmpl, ok := commandResponses[command.Command]; ok {
+		tmpl = commandTmpl
+	}
+	var text strings.Builder
+	if err := tmpl.Execute(&text, command); err != nil {
+		logError("Error rendering response template: %v", err)
+		text.Reset()
+		fallback.Execute(&text, command)
+	}
+	return text.String()
+}
+
+// writeSlackResponse acknowledges a command with a JSON message using the configured response type
+func writeSlackResponse(w http.ResponseWriter, text string) {
+	if responseType == responseTypeEmpty {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	writeSlackMessage(w, http.StatusOK, SlackResponse{ResponseType: responseType, Text: text})
+}
+
+// writeEphemeralResponse replies with a message visible only to the invoking user,
+// regardless of the configured response type
+func writeEphemeralResponse(w http.ResponseWriter, status int, text string) {
+	writeSlackMessage(w, status, SlackResponse{ResponseType: responseTypeEphemeral, Text: text})
+}
+
+// writeSlackMessage writes a Slack message as a JSON response body
+func writeSlackMessage(w http.ResponseWriter, status int, message SlackResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(message); err != nil {
+		logError("Error writing Slack response: %v", err)
+	}
+}
+
+// channelForCommand returns the Redis channel a command is routed to,
+// falling back to redisChannel when it has no entry in COMMAND_CHANNEL_MAP
+func channelForCommand(command string) string {
+	if channel, ok := commandChannels[command]; ok {
+		return channel
+	}
+	return redisChannel
+}
+
+// redisChannelFor returns the Redis channel a command is published to: the
+// request's channel override if it has one, otherwise its routed channel,
+// suffixed with the enterprise ID when ROUTE_BY_ENTERPRISE is enabled
+func redisChannelFor(command SlackCommand, channelOverride string) string {
+	if channelOverride != "" {
+		return channelOverride
+	}
+	channel := channelForCommand(command.Command)
+	if routeByEnterprise && command.EnterpriseID != "" {
+		channel += ":" + command.EnterpriseID
+	}
+	return channel
+}
+
+// publishTarget describes where a command is published: the Redis channel it is
+// routed to and the names of the other configured backends
+func publishTarget(command SlackCommand, channelOverride string) string {
+	targets := make([]string, len(backends))
+	for i, name := range backends {
+		targets[i] = name
+		if name == backendRedis {
+			targets[i] = redisChannelFor(command, channelOverride)
 		}
 	}
+	return strings.Join(targets, ", ")
+}
 
-	w.WriteHeader(http.StatusOK)
+// prefixChannels returns a copy of a command to channel map with prefix
+// prepended to every channel
+func prefixChannels(prefix string, channels map[string]string) map[string]string {
+	if prefix == "" || channels == nil {
+		return channels
+	}
+	prefixed := make(map[string]string, len(channels))
+	for command, channel := range channels {
+		prefixed[command] = prefix + channel
+	}
+	return prefixed
 }
 
-func main() {
-	// Set log level from environment variable
-	logLevelStr := os.Getenv("LOG_LEVEL")
-	if logLevelStr == "" {
-		logLevelStr = "INFO"
+// parseChannelMap parses COMMAND_CHANNEL_MAP, given either as a JSON object or as
+// comma-separated command:channel pairs such as "/deploy:deploy-events,/status:status-events"
+func parseChannelMap(value string) (map[string]string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
 	}
-	currentLogLevel = parseLogLevel(logLevelStr)
-	logInfo("Log level set to: %s", strings.ToUpper(logLevelStr))
 
-	// Get Redis channel name from environment variable
-	redisChannel = os.Getenv("REDIS_CHANNEL")
-	if redisChannel == "" {
-		redisChannel = "slack-commands"
+	channels := make(map[string]string)
+	if strings.HasPrefix(value, "{") {
+		if err := json.Unmarshal([]byte(value), &channels); err != nil {
+			return nil, fmt.Errorf("parsing JSON: %w", err)
+		}
+		return channels, nil
 	}
-	logInfo("Redis channel set to: %s", redisChannel)
 
-	// Load Slack signing secret from .secret file
-	secretData, err := os.ReadFile(".secret")
-	if err != nil {
-		logWarn(".secret file not found. Slack signature verification will be skipped.")
-		logWarn("To enable verification, create a .secret file with your Slack signing secret.")
-	} else {
-		signingSecret = []byte(strings.TrimSpace(string(secretData)))
-		logInfo("Slack signing secret loaded. Signature verification enabled.")
+	for _, pair := range splitList(value) {
+		command, channel, ok := strings.Cut(pair, ":")
+		command = strings.TrimSpace(command)
+		channel = strings.TrimSpace(channel)
+		if !ok || command == "" || channel == "" {
+			return nil, fmt.Errorf("invalid entry %q, expected command:channel", pair)
+		}
+		channels[command] = channel
 	}
+	return channels, nil
+}
 
-	// Configure Redis connection
-	redisHost := os.Getenv("REDIS_HOST")
-	redisPort := os.Getenv("REDIS_PORT")
-	redisPassword := os.Getenv("REDIS_PASSWORD")
+// publishToRedis delivers the JSON payload to the Redis channel using the configured mode.
+// In stream mode a few fields and the partition key are duplicated alongside the payload so
+// consumers can filter or shard on them; the other modes ignore the key.
+// With REDIS_METRICS, countCommand also increments the command's counters in the same round trip.
+func publishToRedis(ctx context.Context, channel, key string, command SlackCommand, jsonPayload []byte, countCommand bool) error {
+	client := getRedisClient()
+	if client == nil {
+		return errRedisUnavailable
+	}
+	jsonPayload, err := encodeRedisPayload(jsonPayload)
+	if err != nil {
+		return err
+	}
+	if !countCommand || !redisMetrics {
+		return queueRedisPublish(ctx, client, channel, key, command, jsonPayload).Err()
+	}
 
-	// Set defaults
-	if redisHost == "" {
-		redisHost = "localhost"
+	var publish redis.Cmder
+	_, err = client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		publish = queueRedisPublish(ctx, pipe, channel, key, command, jsonPayload)
+		for _, key := range commandMetricKeys(command.Command, time.Now()) {
+			pipe.Incr(ctx, key)
+		}
+		return nil
+	})
+	// Only a failed publish is retried; a failed counter must not publish the command twice
+	if err := pipelinedErr(publish, err); err != nil {
+		return err
 	}
-	if redisPort == "" {
-		redisPort = "6379"
+	if err != nil {
+		logWarn("Error updating Redis metrics for command %s: %v", command.Command, err)
 	}
+	return nil
+}
 
-	// Initialize Redis client
-	redisAddr := fmt.Sprintf("%s:%s", redisHost, redisPort)
-	redisOpts := &redis.Options{
-		Addr: redisAddr,
+// pipelinedErr returns the result of cmd from a pipeline that returned err.
+// go-redis only sets errors on commands that got a reply, so a connection error
+// is reported for every command without one.
+func pipelinedErr(cmd redis.Cmder, err error) error {
+	if cmdErr := cmd.Err(); cmdErr != nil {
+		return cmdErr
 	}
-	if redisPassword != "" {
-		redisOpts.Password = redisPassword
+	var replyErr redis.Error
+	if err != nil && !errors.As(err, &replyErr) {
+		return err
 	}
-	redisClient = redis.NewClient(redisOpts)
+	return nil
+}
 
-	// Test Redis connection with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	_, err = redisClient.Ping(ctx).Result()
+// encodeRedisPayload applies PAYLOAD_COMPRESSION to a payload before it is
+// written to Redis
+func encodeRedisPayload(jsonPayload []byte) ([]byte, error) {
+	if payloadCompression != compressionGzip {
+		return jsonPayload, nil
+	}
+	compressed, err := gzipPayload(jsonPayload)
 	if err != nil {
-		logWarn("Could not connect to Redis at %s: %v", redisAddr, err)
-		logWarn("Redis publishing will be disabled. Service will continue to work without Redis.")
-		redisClient = nil
+		return nil, fmt.Errorf("compressing payload: %w", err)
+	}
+	return compressed, nil
+}
+
+// commandMetricKeys returns the REDIS_METRICS counters incremented for a
+// command: an all-time count and a count for the current UTC day
+func commandMetricKeys(command string, now time.Time) []string {
+	base := channelPrefix + "slack:commands:" + command
+	return []string{
+		base + ":count",
+		base + ":" + now.UTC().Format(time.DateOnly),
+	}
+}
+
+// queueRedisPublish issues the publish command for the configured mode on c,
+// which is either the client or a pipeline
+func queueRedisPublish(ctx context.Context, c redis.Cmdable, channel, key string, command SlackCommand, jsonPayload []byte) redis.Cmder {
+	switch redisMode {
+	case redisModeStream:
+		values := map[string]interface{}{
+			"payload":    jsonPayload,
+			"command":    command.Command,
+			"user_id":    command.UserID,
+			"team_id":    command.TeamID,
+			"channel_id": command.ChannelID,
+		}
+		if key != "" {
+			values["partition_key"] = key
+		}
+		if payloadCompression == compressionGzip {
+			values["content_encoding"] = compressionGzip
+		}
+		return c.XAdd(ctx, &redis.XAddArgs{Stream: channel, Values: values})
+	case redisModeList:
+		return c.LPush(ctx, channel, jsonPayload)
+	default:
+		return c.Publish(ctx, channel, jsonPayload)
+	}
+}
+
+// healthHandler reports whether Redis is currently reachable by actively pinging it.
+// Backends other than Redis are not probed.
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	if !hasBackend(backendRedis) {
+		writeHealth(w, r, http.StatusOK, map[string]string{
+			"status":  "ok",
+			"backend": strings.Join(backends, ","),
+		})
+		return
+	}
+
+	status := http.StatusOK
+	redisStatus := "connected"
+
+	client := getRedisClient()
+	if client == nil {
+		status = http.StatusServiceUnavailable
+		redisStatus = "disconnected"
+	} else if !redisConnected.Load() {
+		// The initial connection is still being retried in the background
+		status = http.StatusServiceUnavailable
+		redisStatus = "connecting"
 	} else {
-		logInfo("Connected to Redis at %s", redisAddr)
+		ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+		defer cancel()
+		if err := client.Ping(ctx).Err(); err != nil {
+			logWarn("Health check Redis ping failed: %v", err)
+			status = http.StatusServiceUnavailable
+			redisStatus = "disconnected"
+		}
 	}
 
-	http.HandleFunc("/command", slackCommandHandler)
+	writeHealth(w, r, status, map[string]string{
+		"status": "ok",
+		"redis":  redisStatus,
+	})
+}
 
-	// Get port from environment variable, default to 8080
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+// writeHealth writes the health status as JSON, or as a bare "ok" or "degraded"
+// for probes whose Accept header prefers text/plain
+func writeHealth(w http.ResponseWriter, r *http.Request, status int, body map[string]string) {
+	if prefersPlainText(r.Header.Get("Accept")) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			w.Write([]byte("ok"))
+		} else {
+			w.Write([]byte("degraded"))
+		}
+		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
 
-	// Ensure port has colon prefix
-	if !strings.HasPrefix(port, ":") {
-		port = ":" + port
+// prefersPlainText reports whether an Accept header ranks text/plain above
+// application/json. Ties, including a missing header or */*, favour JSON.
+func prefersPlainText(accept string) bool {
+	var textQ, jsonQ float64
+	for _, part := range splitList(accept) {
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		switch mediaType {
+		case "text/plain", "text/*":
+			textQ = max(textQ, q)
+		case "application/json", "application/*":
+			jsonQ = max(jsonQ, q)
+		case "*/*":
+			textQ, jsonQ = max(textQ, q), max(jsonQ, q)
+		}
+	}
+	return textQ > jsonQ
+}
+
+// livezHandler reports that the process is serving requests
+func livezHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
+// readyzHandler reports whether startup has completed
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	if !ready.Load() {
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
+// requireReady rejects requests with 503 until startup has completed, so handlers
+// never observe partially initialized state
+func requireReady(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !ready.Load() {
+			http.Error(w, "Service not ready", http.StatusServiceUnavailable)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// withRequestTimeout bounds a handler end-to-end, replying 503 and cancelling
+// the request context when the timeout expires. A zero timeout disables it.
+func withRequestTimeout(next http.HandlerFunc, timeout time.Duration) http.HandlerFunc {
+	if timeout <= 0 {
+		return next
+	}
+	return http.TimeoutHandler(next, timeout, "Request timed out").ServeHTTP
+}
+
+// withConcurrencyLimit tracks in-flight commands and, when limit is positive,
+// rejects requests beyond it with 429 instead of queueing them
+func withConcurrencyLimit(next http.HandlerFunc, limit int) http.HandlerFunc {
+	var slots chan struct{}
+	if limit > 0 {
+		slots = make(chan struct{}, limit)
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if slots != nil {
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			default:
+				logWarn("Rejected request: %d commands already in flight", limit)
+				writeEphemeralResponse(w, http.StatusTooManyRequests, concurrencyLimitMessage)
+				return
+			}
+		}
+		inFlightRequests.Inc()
+		defer inFlightRequests.Dec()
+		next(w, r)
+	}
+}
+
+// normalizeHTTPPath ensures the path has a leading slash and no trailing slash
+func normalizeHTTPPath(path string) string {
+	path = strings.Trim(strings.TrimSpace(path), "/")
+	return "/" + path
+}
+
+// handleCommandPath registers handler at path, matching it with or without a trailing slash
+func handleCommandPath(path string, handler http.HandlerFunc) {
+	if path == "/" {
+		http.HandleFunc("/{$}", handler)
+		return
+	}
+	http.HandleFunc(path, handler)
+	http.HandleFunc(path+"/{$}", handler)
+}
+
+// httpTimeouts bounds how long a client may take to send a request, wait for
+// the response or hold an idle connection
+type httpTimeouts struct {
+	readHeader time.Duration
+	read       time.Duration
+	write      time.Duration
+	idle       time.Duration
+}
+
+// httpTimeoutsFromEnv reads the HTTP_*_TIMEOUT variables, falling back to the
+// defaults for unset or invalid values
+func httpTimeoutsFromEnv() httpTimeouts {
+	return httpTimeouts{
+		readHeader: getEnvDuration("HTTP_READ_HEADER_TIMEOUT", defaultReadHeaderTimeout),
+		read:       getEnvDuration("HTTP_READ_TIMEOUT", defaultReadTimeout),
+		write:      getEnvDuration("HTTP_WRITE_TIMEOUT", defaultWriteTimeout),
+		idle:       getEnvDuration("HTTP_IDLE_TIMEOUT", defaultIdleTimeout),
+	}
+}
+
+// redisOptionsFromEnv builds the Redis client options from the environment.
+// REDIS_URL takes precedence; otherwise the individual REDIS_* variables are used.
+func redisOptionsFromEnv() (*redis.Options, error) {
+	var opts *redis.Options
+	if redisURL := os.Getenv("REDIS_URL"); redisURL != "" {
+		parsed, err := redis.ParseURL(redisURL)
+		if err != nil {
+			return nil, fmt.Errorf("parsing REDIS_URL: %w", err)
+		}
+		opts = parsed
+	} else {
+		redisHost := os.Getenv("REDIS_HOST")
+		redisPort := os.Getenv("REDIS_PORT")
+		redisUsername := os.Getenv("REDIS_USERNAME")
+		redisPassword := os.Getenv("REDIS_PASSWORD")
+
+		// Set defaults
+		if redisHost == "" {
+			redisHost = "localhost"
+		}
+		if redisPort == "" {
+			redisPort = "6379"
+		}
+
+		opts = &redis.Options{
+			Addr: fmt.Sprintf("%s:%s", redisHost, redisPort),
+			DB:   getEnvInt("REDIS_DB", 0),
+		}
+		if redisPassword != "" {
+			opts.Username = redisUsername
+			opts.Password = redisPassword
+		}
+	}
+
+	// A rediss:// URL already enables TLS; REDIS_TLS enables it for the other paths
+	if opts.TLSConfig == nil && getEnvBool("REDIS_TLS", false) {
+		opts.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	if opts.TLSConfig != nil && getEnvBool("REDIS_TLS_SKIP_VERIFY", false) {
+		logWarn("REDIS_TLS_SKIP_VERIFY is enabled. Redis server certificates will not be verified.")
+		opts.TLSConfig.InsecureSkipVerify = true
+	}
+
+	// Unset values keep the go-redis defaults
+	if poolSize := getEnvInt("REDIS_POOL_SIZE", 0); poolSize > 0 {
+		opts.PoolSize = poolSize
+	}
+	if minIdleConns := getEnvInt("REDIS_MIN_IDLE_CONNS", 0); minIdleConns > 0 {
+		opts.MinIdleConns = minIdleConns
+	}
+	if dialTimeout := getEnvDuration("REDIS_DIAL_TIMEOUT", 0); dialTimeout > 0 {
+		opts.DialTimeout = dialTimeout
+	}
+
+	return opts, nil
+}
+
+// newRedisClient creates a cluster client when REDIS_CLUSTER_ADDRS is set and a
+// single-node client otherwise. It also returns a description of the target for logging.
+func newRedisClient(opts *redis.Options) (redis.UniversalClient, string) {
+	if clusterAddrs := splitList(os.Getenv("REDIS_CLUSTER_ADDRS")); len(clusterAddrs) > 0 {
+		client := redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:        clusterAddrs,
+			Username:     opts.Username,
+			Password:     opts.Password,
+			TLSConfig:    opts.TLSConfig,
+			PoolSize:     opts.PoolSize,
+			MinIdleConns: opts.MinIdleConns,
+			DialTimeout:  opts.DialTimeout,
+		})
+		return client, fmt.Sprintf("cluster %s (tls %t)", strings.Join(clusterAddrs, ","), opts.TLSConfig != nil)
+	}
+	return redis.NewClient(opts), fmt.Sprintf("%s (db %d, tls %t)", opts.Addr, opts.DB, opts.TLSConfig != nil)
+}
+
+// redisPoolSummary describes the effective pool settings, filling in the
+// go-redis defaults for anything left unset
+func redisPoolSummary(opts *redis.Options) string {
+	poolSize := opts.PoolSize
+	if poolSize == 0 {
+		poolSize = 10 * runtime.GOMAXPROCS(0)
+	}
+	dialTimeout := opts.DialTimeout
+	if dialTimeout == 0 {
+		dialTimeout = 5 * time.Second
+	}
+	return fmt.Sprintf("size %d, min idle %d, dial timeout %s", poolSize, opts.MinIdleConns, dialTimeout)
+}
+
+// connectRedis creates a Redis client and verifies the connection with a ping.
+// If Redis is unreachable, e.g. because it is starting alongside the relay, the
+// client is still returned and the connection retried in the background;
+// publishing is enabled once a ping succeeds.
+func connectRedis(opts *redis.Options) redis.UniversalClient {
+	client, target := newRedisClient(opts)
+	serverSettings.redisTarget = target
+	logInfo("Redis connection pool: %s", redisPoolSummary(opts))
+
+	ping := func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		return client.Ping(ctx).Err()
+	}
+	if redisHealthCheckInterval > 0 {
+		go monitorRedis(context.Background(), target, ping, redisHealthCheckInterval)
+	}
+	if err := ping(context.Background()); err != nil {
+		logRedisConnectError(target, err)
+		logWarn("Redis publishing is paused; retrying the connection in the background.")
+		go waitForRedis(context.Background(), target, ping, retryInitialBackoff, defaultRetryMaxBackoff)
+		return client
+	}
+
+	redisConnected.Store(true)
+	logInfo("Connected to Redis at %s", target)
+	return client
+}
+
+// monitorRedis pings Redis every interval once it has connected, pausing
+// publishing while it is unreachable and re-enabling it when it recovers.
+// While paused, commands fail fast and wait in the retry queue instead of each
+// waiting out a publish timeout. It runs until ctx is cancelled.
+func monitorRedis(ctx context.Context, target string, ping func(context.Context) error, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := ping(ctx)
+		switch {
+		case err != nil && redisConnected.Load():
+			redisConnected.Store(false)
+			logWarn("Lost connection to Redis at %s, pausing publishing: %v", target, err)
+		case err == nil && !redisConnected.Load():
+			redisConnected.Store(true)
+			logInfo("Reconnected to Redis at %s; publishing enabled", target)
+		}
+	}
+}
+
+// logRedisConnectError logs a failed connection attempt, calling out bad credentials
+func logRedisConnectError(target string, err error) {
+	if redis.IsAuthError(err) {
+		logError("Redis authentication failed at %s: %v", target, err)
+		logError("Check the Redis username and password.")
+		return
+	}
+	logWarn("Could not connect to Redis at %s: %v", target, err)
+}
+
+// waitForRedis pings Redis with exponential backoff until it answers, then
+// enables publishing. It gives up only when ctx is cancelled.
+func waitForRedis(ctx context.Context, target string, ping func(context.Context) error, initialBackoff, maxBackoff time.Duration) {
+	backoff := initialBackoff
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if redisConnected.Load() {
+			// The health check got there first
+			return
+		}
+
+		err := ping(ctx)
+		if err == nil {
+			redisConnected.Store(true)
+			logInfo("Connected to Redis at %s; publishing enabled", target)
+			return
+		}
+		backoff = min(backoff*2, maxBackoff)
+		logRedisConnectError(target, err)
+		logDebug("Next Redis connection attempt in %s", backoff)
+	}
+}
+
+func main() {
+	// Merge settings from CONFIG_FILE; environment variables take precedence
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		values, err := loadConfigFile(path)
+		if err != nil {
+			logError("Error loading CONFIG_FILE %s: %v", path, err)
+			os.Exit(1)
+		}
+		applyConfigFile(values)
+		logInfo("Loaded %d settings from %s", len(values), path)
+	}
+	cfg := loadConfig()
+
+	// Set log level from configuration
+	logLevelStr := cfg.LogLevel
+	currentLogLevel = parseLogLevel(logLevelStr)
+	logFormat := parseLogFormat(cfg.LogFormat)
+	setLogOutput(os.Stderr, logFormat)
+
+	// Fail fast on misconfiguration instead of falling back to defaults at runtime
+	checkConfig := flag.Bool("check-config", false, "validate the configuration and exit")
+	flag.Parse()
+	if err := validateConfig(); err != nil {
+		for _, problem := range err.(interface{ Unwrap() []error }).Unwrap() {
+			logError("Invalid configuration: %v", problem)
+		}
+		os.Exit(1)
+	}
+	if *checkConfig {
+		logInfo("Configuration is valid")
+		os.Exit(0)
+	}
+
+	// Write logs to LOG_FILE, optionally mirrored to stderr
+	if path := os.Getenv("LOG_FILE"); path != "" {
+		maxBytes := int64(getEnvInt("LOG_FILE_MAX_MB", defaultLogFileMaxMB)) << 20
+		file, err := openRotatingFile(path, maxBytes, getEnvInt("LOG_FILE_BACKUPS", defaultLogFileBackups))
+		if err != nil {
+			logError("Could not open LOG_FILE: %v", err)
+			os.Exit(1)
+		}
+		var out io.Writer = file
+		if getEnvBool("LOG_STDERR", false) {
+			out = io.MultiWriter(file, os.Stderr)
+		}
+		setLogOutput(out, logFormat)
+		go reopenOnSIGHUP(file)
+		logInfo("Logging to %s (rotating at %d MB, keeping %d backups)", path, maxBytes>>20, file.backups)
+	}
+
+	logInfo("Log level set to: %s", strings.ToUpper(logLevelStr))
+
+	// REDIS_CHANNEL_PREFIX namespaces every channel and list the relay writes to,
+	// so several environments can share one Redis
+	channelPrefix = os.Getenv("REDIS_CHANNEL_PREFIX")
+	if channelPrefix != "" {
+		logInfo("Redis channel prefix set to: %s", channelPrefix)
+	}
+
+	redisChannel = channelPrefix + cfg.RedisChannel
+	logInfo("Redis channel set to: %s", redisChannel)
+	interactiveChannel = channelPrefix + getEnvString("REDIS_INTERACTIVE_CHANNEL", defaultInteractiveChannel)
+	logInfo("Redis interactive channel set to: %s", interactiveChannel)
+	shortcutChannel = channelPrefix + getEnvString("REDIS_SHORTCUT_CHANNEL", defaultShortcutChannel)
+	logInfo("Redis shortcut channel set to: %s", shortcutChannel)
+
+	// Consumers can watch the control channel to coordinate with relay deployments
+	emitLifecycleEvents = getEnvBool("EMIT_LIFECYCLE_EVENTS", false)
+	controlChannel = channelPrefix + getEnvString("REDIS_CONTROL_CHANNEL", defaultControlChannel)
+	if emitLifecycleEvents {
+		logInfo("Publishing lifecycle events to Redis channel: %s", controlChannel)
+	}
+
+	routeByEnterprise = getEnvBool("ROUTE_BY_ENTERPRISE", false)
+	if routeByEnterprise {
+		logInfo("Routing Enterprise Grid commands to channels suffixed with :<enterprise_id>")
+	}
+
+	channels, err := parseChannelMap(os.Getenv("COMMAND_CHANNEL_MAP"))
+	if err != nil {
+		logError("Invalid COMMAND_CHANNEL_MAP: %v", err)
+		os.Exit(1)
+	}
+	commandChannels = prefixChannels(channelPrefix, channels)
+	for command, channel := range commandChannels {
+		logInfo("Routing command %s to Redis channel: %s", command, channel)
+	}
+
+	if hostname, err := os.Hostname(); err == nil {
+		relaySource = hostname
+	}
+	logInfo("Relay source set to: %s", relaySource)
+
+	timestampFormat = parseTimestampFormat(os.Getenv("TIMESTAMP_FORMAT"))
+	logInfo("Timestamp format set to: %s", timestampFormat)
+	payloadCase = parsePayloadCase(os.Getenv("PAYLOAD_CASE"))
+	logInfo("Payload key case set to: %s", payloadCase)
+
+	redisMode = parseRedisMode(os.Getenv("REDIS_MODE"))
+	payloadCompression = parseCompression(os.Getenv("COMPRESS_PAYLOAD"))
+	if payloadCompression != compressionNone {
+		logInfo("Redis payload compression set to: %s", payloadCompression)
+	}
+	logInfo("Redis mode set to: %s", redisMode)
+
+	if value := os.Getenv("BACKENDS"); value != "" {
+		backends = parseBackends(value)
+	} else {
+		backends = []string{parseBackend(os.Getenv("BACKEND"))}
+	}
+	// KAFKA_PARTITION_KEY predates PARTITION_KEY, which applies to every backend
+	if os.Getenv("PARTITION_KEY") == "" && os.Getenv("KAFKA_PARTITION_KEY") != "" {
+		logWarn("KAFKA_PARTITION_KEY is deprecated, use PARTITION_KEY instead")
+	}
+	partitionKey = parsePartitionKey(getEnvString("PARTITION_KEY", os.Getenv("KAFKA_PARTITION_KEY")))
+
+	redisPublishTimeout = getEnvDuration("REDIS_PUBLISH_TIMEOUT", defaultRedisPublishTimeout)
+	logInfo("Redis publish timeout set to: %s", redisPublishTimeout)
+	redisHealthCheckInterval = getEnvDuration("REDIS_HEALTHCHECK_INTERVAL", defaultRedisHealthCheckInterval)
+	batchSize = getEnvInt("BATCH_SIZE", 0)
+	batchInterval = getEnvDuration("BATCH_INTERVAL", defaultBatchInterval)
+
+	if rawWebhookURL := os.Getenv("WEBHOOK_URL"); rawWebhookURL != "" {
+		parsed, err := validateWebhookURL(rawWebhookURL)
+		if err != nil {
+			logError("Invalid WEBHOOK_URL: %v", err)
+			os.Exit(1)
+		}
+		webhookURL = rawWebhookURL
+		webhookClient.Timeout = getEnvDuration("WEBHOOK_TIMEOUT", defaultWebhookTimeout)
+		webhookRetry = getEnvBool("WEBHOOK_RETRY", false)
+		logInfo("Forwarding commands to webhook at %s (timeout %s, retry %t)", parsed.Host, webhookClient.Timeout, webhookRetry)
+
+		// WEBHOOK_URL alone forwards in addition to the other backends
+		if !hasBackend(backendWebhook) {
+			backends = append(backends, backendWebhook)
+		}
+	}
+	// Skip Redis entirely, including the connection attempt and its warnings
+	if !getEnvBool("REDIS_ENABLED", true) {
+		backends = withoutBackend(backends, backendRedis)
+		logInfo("Redis disabled by REDIS_ENABLED=false")
+	}
+	if len(backends) == 0 {
+		logWarn("No publishing backends configured. Commands will be acknowledged but not published.")
+	} else {
+		logInfo("Publishing backends set to: %s", strings.Join(backends, ","))
+	}
+
+	teamAllowlist = toSet(splitList(os.Getenv("TEAM_ALLOWLIST")))
+	if teamAllowlist != nil {
+		logInfo("Team allowlist set to: %s", os.Getenv("TEAM_ALLOWLIST"))
+	}
+
+	commandAllowlist = toSet(splitList(os.Getenv("COMMAND_ALLOWLIST")))
+	if commandAllowlist != nil {
+		logInfo("Command allowlist set to: %s", os.Getenv("COMMAND_ALLOWLIST"))
+	}
+
+	commandDenylist = toSet(splitList(os.Getenv("COMMAND_DENYLIST")))
+	if commandDenylist != nil {
+		logInfo("Command denylist set to: %s", os.Getenv("COMMAND_DENYLIST"))
+	}
+	if message := os.Getenv("COMMAND_DENYLIST_MESSAGE"); message != "" {
+		denylistMessage = message
+	}
+
+	channelAllowlist = toSet(splitList(os.Getenv("CHANNEL_ALLOWLIST")))
+	if channelAllowlist != nil {
+		logInfo("Channel allowlist set to: %s", os.Getenv("CHANNEL_ALLOWLIST"))
+	}
+	commandChannelAllowlists, err = parseCommandChannelAllowlists(os.Getenv("COMMAND_CHANNEL_ALLOWLIST"))
+	if err != nil {
+		logError("Invalid COMMAND_CHANNEL_ALLOWLIST: %v", err)
+		os.Exit(1)
+	}
+	for command, channels := range commandChannelAllowlists {
+		logInfo("Command %s allowed in channels: %s", command, strings.Join(sortedKeys(channels), ","))
+	}
+
+	if rateLimitRPS := getEnvFloat("RATE_LIMIT_RPS", 0); rateLimitRPS > 0 {
+		rateLimitBurst := getEnvInt("RATE_LIMIT_BURST", 1)
+		if rateLimitBurst < 1 {
+			rateLimitBurst = 1
+		}
+		rateLimitKey = parseRateLimitKey(os.Getenv("RATE_LIMIT_KEY"))
+		commandRateLimiter = newRateLimiter(rateLimitRPS, rateLimitBurst)
+		logInfo("Rate limiting enabled: %g requests/s per %s (burst %d)", rateLimitRPS, rateLimitKey, rateLimitBurst)
+	}
+
+	responseType = parseResponseType(os.Getenv("RESPONSE_TYPE"))
+	logInfo("Slack response type set to: %s", responseType)
+
+	// Validate the response template once so a bad template fails fast
+	if responseTemplateStr := os.Getenv("RESPONSE_TEMPLATE"); responseTemplateStr != "" {
+		tmpl, err := template.New("response").Parse(responseTemplateStr)
+		if err != nil {
+			logError("Invalid RESPONSE_TEMPLATE: %v", err)
+			os.Exit(1)
+		}
+		responseTemplate = tmpl
+		logInfo("Custom response template loaded")
+	}
+
+	if value := os.Getenv("ASYNC_RESPONSE_TEMPLATE"); value != "" {
+		tmpl, err := template.New("async_response").Parse(value)
+		if err != nil {
+			logError("Invalid ASYNC_RESPONSE_TEMPLATE: %v", err)
+			os.Exit(1)
+		}
+		asyncResponseTemplate = tmpl
+		logInfo("Custom async response template loaded")
+	}
+
+	if value := os.Getenv("ERROR_RESPONSE_TEMPLATE"); value != "" {
+		tmpl, err := template.New("error_response").Parse(value)
+		if err != nil {
+			logError("Invalid ERROR_RESPONSE_TEMPLATE: %v", err)
+			os.Exit(1)
+		}
+		errorResponseTemplate = tmpl
+		logInfo("Custom error response template loaded")
+	}
+
+	responses, err := parseCommandResponses(os.Getenv("COMMAND_RESPONSES"))
+	if err != nil {
+		logError("Invalid COMMAND_RESPONSES: %v", err)
+		os.Exit(1)
+	}
+	commandResponses = responses
+	if len(commandResponses) > 0 {
+		logInfo("Per-command response templates loaded for: %s", strings.Join(slices.Sorted(maps.Keys(commandResponses)), ","))
+	}
+
+	if getEnvBool("OTEL_ENABLED", false) {
+		if err := setupTracing(context.Background()); err != nil {
+			logError("Failed to set up OpenTelemetry tracing: %v", err)
+			os.Exit(1)
+		}
+		logInfo("OpenTelemetry tracing enabled")
+	}
+
+	metricsCommandLabel = getEnvBool("METRICS_COMMAND_LABEL", true)
+	logInfo("Metrics command label enabled: %t", metricsCommandLabel)
+
+	secretsMap, err = parseSecretsMap(os.Getenv("SECRETS_MAP"))
+	if err != nil {
+		logError("Invalid SECRETS_MAP: %v", err)
+		os.Exit(1)
+	}
+	if len(secretsMap) > 0 {
+		logInfo("Signing secrets mapped for %d team or app IDs from SECRETS_MAP", len(secretsMap))
+	}
+	loadSigningSecrets(cfg.SecretFile)
+
+	tolerance := getEnvInt("SLACK_TIMESTAMP_TOLERANCE", slackTimestampToleranceSeconds)
+	if tolerance <= 0 {
+		logWarn("SLACK_TIMESTAMP_TOLERANCE must be positive, using default %d", slackTimestampToleranceSeconds)
+		tolerance = slackTimestampToleranceSeconds
+	}
+	// TIMESTAMP_TOLERANCE_PAST and TIMESTAMP_TOLERANCE_FUTURE refine either side of the window
+	timestampPastToleranceSeconds = int64(getEnvInt("TIMESTAMP_TOLERANCE_PAST", tolerance))
+	timestampFutureToleranceSeconds = int64(getEnvInt("TIMESTAMP_TOLERANCE_FUTURE", tolerance))
+	logInfo("Slack timestamp tolerance set to: %ds past, %ds future", timestampPastToleranceSeconds, timestampFutureToleranceSeconds)
+
+	// An empty REDACT_FIELDS publishes every field, including the token
+	if value, ok := os.LookupEnv("REDACT_FIELDS"); ok {
+		redactFields = toSet(splitList(value))
+	}
+	if len(redactFields) > 0 {
+		logInfo("Redacting command fields before publishing: %s", strings.Join(slices.Sorted(maps.Keys(redactFields)), ","))
+	} else {
+		logWarn("REDACT_FIELDS is empty. The Slack verification token will be published.")
+	}
+
+	commandNormalization, err = parseCommandNormalization(os.Getenv("NORMALIZE_COMMAND"))
+	if err != nil {
+		logError("Invalid NORMALIZE_COMMAND: %v", err)
+		os.Exit(1)
+	}
+	if len(commandNormalization) > 0 {
+		logInfo("Normalizing published command names: %s", strings.Join(slices.Sorted(maps.Keys(commandNormalization)), ","))
+	}
+
+	networks, err := parseTrustedIPs(os.Getenv("TRUSTED_IPS"))
+	if err != nil {
+		logError("Invalid TRUSTED_IPS: %v", err)
+		os.Exit(1)
+	}
+	trustedNetworks = networks
+	if len(trustedNetworks) > 0 {
+		logWarn("TRUSTED_IPS is set. Requests from %v will skip Slack signature verification. Never use this in production.", trustedNetworks)
+	}
+
+	dedupe = getEnvBool("DEDUPE", false)
+	if dedupe {
+		dedupeTTL = getEnvDuration("DEDUPE_TTL", defaultDedupeTTL)
+		if hasBackend(backendRedis) {
+			logInfo("Dropping duplicate deliveries seen within %s", dedupeTTL)
+		} else {
+			logWarn("DEDUPE needs Redis, which is not a configured backend; duplicates will not be dropped")
+			dedupe = false
+		}
+	}
+
+	redisMetrics = getEnvBool("REDIS_METRICS", false)
+	if redisMetrics {
+		logInfo("Counting published commands in Redis keys %s", channelPrefix+"slack:commands:<command>:count")
+	}
+
+	dryRun = getEnvBool("DRY_RUN", false)
+	if dryRun {
+		logWarn("DRY_RUN is enabled. Commands will be logged but not published.")
+	}
+
+	allowChannelOverride = getEnvBool("ALLOW_CHANNEL_OVERRIDE", false)
+	if allowChannelOverride {
+		pattern, err := parseChannelOverridePattern(os.Getenv("CHANNEL_OVERRIDE_PATTERN"))
+		if err != nil {
+			logError("Invalid CHANNEL_OVERRIDE_PATTERN: %v", err)
+			os.Exit(1)
+		}
+		channelOverridePattern = pattern
+		logWarn("%s overrides enabled for channels matching %s", channelOverrideHeader, pattern)
+	}
+
+	useResponseURL = getEnvBool("USE_RESPONSE_URL", false)
+	if useResponseURL {
+		responseURLMessage = getEnvString("RESPONSE_URL_MESSAGE", defaultResponseURLMessage)
+		logInfo("Posting %q to each command's response_url", responseURLMessage)
+	}
+
+	debugSignatureFailures = getEnvBool("DEBUG_SIGNATURE_FAILURES", false)
+	if debugSignatureFailures {
+		signatureFailureMessage = getEnvString("SIGNATURE_FAILURE_MESSAGE", defaultSignatureFailureMessage)
+		logWarn("DEBUG_SIGNATURE_FAILURES is enabled. Rejected requests will log their team and app IDs.")
+	}
+
+	if token := os.Getenv("SLACK_VERIFICATION_TOKEN"); token != "" {
+		verificationToken = []byte(token)
+		logInfo("Slack verification token configured. Token verification enabled.")
+	}
+
+	commandPath := normalizeHTTPPath(cfg.HTTPPath)
+	logInfo("Command path set to: %s", commandPath)
+
+	maxTextLength = getEnvInt("MAX_TEXT_LENGTH", 0)
+	textOverflow = parseTextOverflow(os.Getenv("TEXT_OVERFLOW"))
+	if maxTextLength > 0 {
+		logInfo("Command text limited to %d characters (%s when exceeded)", maxTextLength, textOverflow)
+	}
+
+	if limit := getEnvInt("MAX_BODY_BYTES", defaultMaxBodyBytes); limit > 0 {
+		maxBodyBytes = int64(limit)
+	} else {
+		logWarn("MAX_BODY_BYTES must be positive, using default %d", defaultMaxBodyBytes)
+	}
+
+	requestTimeout := getEnvOptionalDuration("REQUEST_TIMEOUT", defaultRequestTimeout)
+	if requestTimeout > 0 {
+		logInfo("Request timeout set to: %s", requestTimeout)
+	} else {
+		logInfo("Request timeout disabled")
+	}
+
+	// Slack calls the relay over the internet, so bound how long a client may
+	// take to send a request or hold an idle connection
+	timeouts := httpTimeoutsFromEnv()
+	logInfo("HTTP server timeouts: read header %s, read %s, write %s, idle %s", timeouts.readHeader, timeouts.read, timeouts.write, timeouts.idle)
+	if timeouts.write <= requestTimeout {
+		logWarn("HTTP_WRITE_TIMEOUT (%s) should exceed REQUEST_TIMEOUT (%s), or timed-out commands get no response", timeouts.write, requestTimeout)
+	}
+
+	allowGetPing = getEnvBool("ALLOW_GET_PING", false)
+	if allowGetPing {
+		logInfo("GET %s answers 200 ok for uptime checks", commandPath)
+	}
+
+	maxConcurrent := getEnvInt("MAX_CONCURRENT", 0)
+	if maxConcurrent > 0 {
+		logInfo("Limiting in-flight commands to %d", maxConcurrent)
+	}
+
+	commandHandler := requireReady(withConcurrencyLimit(withRequestTimeout(slackCommandHandler, requestTimeout), maxConcurrent))
+	handleCommandPath(commandPath, commandHandler)
+	http.HandleFunc("/health", requireReady(healthHandler))
+	http.HandleFunc("/livez", livezHandler)
+	http.HandleFunc("/readyz", readyzHandler)
+	http.Handle("/metrics", promhttp.Handler())
+	http.HandleFunc("/stats", statsHandler)
+
+	// expvar exposes internals such as the command line, so it is opt-in
+	enableExpvar = getEnvBool("ENABLE_EXPVAR", false)
+	if enableExpvar {
+		publishExpvars()
+		logInfo("expvar enabled at %s", expvarPath)
+	}
+
+	enableGRPC = getEnvBool("ENABLE_GRPC", false)
+	grpcPort := getEnvString("GRPC_PORT", defaultGRPCPort)
+	grpcSubscriberBuffer = getEnvInt("GRPC_SUBSCRIBER_BUFFER", defaultGRPCSubscriberBuffer)
+	if grpcSubscriberBuffer < 1 {
+		logWarn("GRPC_SUBSCRIBER_BUFFER must be positive, using default %d", defaultGRPCSubscriberBuffer)
+		grpcSubscriberBuffer = defaultGRPCSubscriberBuffer
+	}
+
+	// Operator endpoints are only served when an admin token is configured
+	if token := os.Getenv("ADMIN_TOKEN"); token != "" {
+		adminToken = []byte(token)
+		http.HandleFunc("/replay", requireReady(replayHandler))
+		http.HandleFunc("/config", configHandler)
+		logInfo("Admin token configured. /replay and /config enabled.")
+	}
+
+	// The live command feed is an operator tool, so it shares the admin token
+	enableWebSocket = getEnvBool("ENABLE_WEBSOCKET", false)
+	if enableWebSocket && len(adminToken) == 0 {
+		logWarn("ENABLE_WEBSOCKET requires ADMIN_TOKEN, %s disabled", webSocketPath)
+		enableWebSocket = false
+	}
+	if enableWebSocket {
+		go commandFeed.run()
+		http.HandleFunc(webSocketPath, webSocketHandler)
+		logInfo("Live command feed enabled at %s", webSocketPath)
+	}
+
+	// gRPC subscribers receive every command too, so the stream requires the admin token as well
+	if enableGRPC && len(adminToken) == 0 {
+		logWarn("ENABLE_GRPC requires ADMIN_TOKEN, gRPC streaming disabled")
+		enableGRPC = false
+	}
+
+	// BIND_ADDR lists the addresses to listen on, e.g. both an IPv4 and an IPv6 interface
+	addrs, err := parseBindAddrs(os.Getenv("BIND_ADDR"), cfg.Port)
+	if err != nil {
+		logError("Invalid BIND_ADDR: %v", err)
+		os.Exit(1)
+	}
+
+	// UNIX_SOCKET replaces the TCP listeners, e.g. for a proxy sidecar in the same pod
+	network := "tcp"
+	if unixSocket := os.Getenv("UNIX_SOCKET"); unixSocket != "" {
+		if os.Getenv("BIND_ADDR") != "" {
+			logWarn("UNIX_SOCKET is set, ignoring BIND_ADDR")
+		}
+		network = "unix"
+		addrs = []string{unixSocket}
+	}
+
+	shutdownGracePeriod := getEnvDuration("SHUTDOWN_GRACE_PERIOD", defaultShutdownGracePeriod)
+	logInfo("Shutdown grace period set to: %s", shutdownGracePeriod)
+
+	// Serve HTTPS directly when both a certificate and key are configured
+	tlsCertFile := os.Getenv("TLS_CERT_FILE")
+	tlsKeyFile := os.Getenv("TLS_KEY_FILE")
+	if (tlsCertFile == "") != (tlsKeyFile == "") {
+		logError("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+		os.Exit(1)
+	}
+	useTLS := tlsCertFile != ""
+
+	serverSettings.listenAddrs = addrs
+	serverSettings.commandPath = commandPath
+	serverSettings.tls = useTLS
+	serverSettings.requestTimeout = requestTimeout
+	serverSettings.httpTimeouts = map[string]string{
+		"read_header": timeouts.readHeader.String(),
+		"read":        timeouts.read.String(),
+		"write":       timeouts.write.String(),
+		"idle":        timeouts.idle.String(),
+	}
+	serverSettings.shutdownGracePeriod = shutdownGracePeriod
+	serverSettings.maxConcurrent = maxConcurrent
+
+	// Start serving before connecting to Redis so liveness probes succeed during startup
+	servers := make([]*http.Server, len(addrs))
+	serverErr := make(chan error, len(addrs)+1)
+	for i, addr := range addrs {
+		logInfo("Starting Slack command server on %s %s (tls %t)", network, addr, useTLS)
+		server := &http.Server{
+			Addr:              addr,
+			Handler:           hideExpvar(http.DefaultServeMux),
+			ReadHeaderTimeout: timeouts.readHeader,
+			ReadTimeout:       timeouts.read,
+			WriteTimeout:      timeouts.write,
+			IdleTimeout:       timeouts.idle,
+		}
+		servers[i] = server
+		go func() {
+			var listener net.Listener
+			var err error
+			if network == "unix" {
+				listener, err = listenUnix(addr)
+			} else {
+				listener, err = net.Listen("tcp", addr)
+			}
+			if err == nil && useTLS {
+				err = server.ServeTLS(listener, tlsCertFile, tlsKeyFile)
+			} else if err == nil {
+				err = server.Serve(listener)
+			}
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serverErr <- fmt.Errorf("%s: %w", addr, err)
+			}
+		}()
+	}
+
+	// Stream commands to gRPC subscribers alongside the publishing backends
+	if enableGRPC {
+		server, err := startGRPCServer(grpcPort, serverErr)
+		if err != nil {
+			logError("Error starting gRPC server on port %s: %v", grpcPort, err)
+			os.Exit(1)
+		}
+		grpcServer = server
+		serverSettings.grpcPort = grpcPort
+		logInfo("Streaming commands over gRPC on port %s (subscriber buffer %d)", grpcPort, grpcSubscriberBuffer)
+	}
+
+	// Configure the publishing backends
+	var names []string
+	var publishers []Publisher
+	for _, name := range backends {
+		publisher, err := newBackendPublisher(name)
+		if err != nil {
+			logError("Invalid %s backend configuration: %v", name, err)
+			os.Exit(1)
+		}
+		if publisher != nil {
+			names = append(names, name)
+			publishers = append(publishers, publisher)
+		}
+	}
+	activePublisher = newMultiPublisher(names, publishers)
+
+	deadLetter = parseDeadLetterSink(os.Getenv("DEAD_LETTER_CHANNEL"))
+	if sink, ok := deadLetter.(redisDeadLetter); ok {
+		sink.key = channelPrefix + sink.key
+		deadLetter = sink
+	}
+	if deadLetter != nil {
+		logInfo("Commands that fail all publish retries will be written to dead-letter channel %s", os.Getenv("DEAD_LETTER_CHANNEL"))
+	}
+
+	// Start the retry queue for failed publishes
+	retryQueueSize := getEnvInt("RETRY_QUEUE_SIZE", defaultRetryQueueSize)
+	retryMaxBackoff = getEnvDuration("RETRY_MAX_BACKOFF", defaultRetryMaxBackoff)
+	if activePublisher != nil && retryQueueSize > 0 {
+		publishRetryQueue = newRetryQueue(retryQueueSize, func(ctx context.Context, item retryItem) error {
+			ctx = withChannelOverride(withPublishMeta(ctx, item.command, item.requestID), item.channel)
+			return item.target.Publish(ctx, item.key, item.payload)
+		})
+		publishRetryQueue.start()
+		logInfo("Publish retry queue enabled (max %d commands)", retryQueueSize)
+	}
+
+	// Start the async publish workers
+	if workers := getEnvInt("PUBLISH_WORKERS", defaultPublishWorkers); activePublisher != nil && workers > 0 {
+		queueSize := getEnvInt("PUBLISH_QUEUE_SIZE", defaultPublishQueueSize)
+		if queueSize < 1 {
+			logWarn("PUBLISH_QUEUE_SIZE must be positive, using default %d", defaultPublishQueueSize)
+			queueSize = defaultPublishQueueSize
+		}
+		publishQueue = newPublishPool(workers, queueSize)
+		drainTimeout = getEnvDuration("DRAIN_TIMEOUT", defaultDrainTimeout)
+		logInfo("Publishing asynchronously with %d workers (queue size %d)", workers, queueSize)
+	}
+	selectResponseTemplate()
+
+	logInfo("Startup complete. Ready to accept commands.")
+	ready.Store(true)
+	publishLifecycleEvent(context.Background(), lifecycleStarted)
+
+	// Socket Mode receives commands over an outbound connection, so Slack needs no public request URL
+	socketMode = getEnvBool("SOCKET_MODE", false)
+	if socketMode {
+		appToken := os.Getenv("SLACK_APP_TOKEN")
+		if err := validateAppToken(appToken); err != nil {
+			logError("SOCKET_MODE requires SLACK_APP_TOKEN: %v", err)
+			os.Exit(1)
+		}
+		stopSocketMode = startSocketMode(appToken, commandHandler)
+		logInfo("Receiving commands over Slack Socket Mode")
+	}
+
+	// Wait for a shutdown signal or a server failure
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err := <-serverErr:
+		logError("Server failed: %v", err)
+		os.Exit(1)
+	case sig := <-stop:
+		logInfo("Received %s, shutting down", sig)
+	}
+
+	shutdown(servers, shutdownGracePeriod)
+}
+
+// parseBindAddrs returns the listen addresses from BIND_ADDR, a comma-separated
+// list of host:port pairs such as "0.0.0.0:8080,[::]:8080". When it is empty,
+// every interface is served on port.
+func parseBindAddrs(value, port string) ([]string, error) {
+	if strings.TrimSpace(value) == "" {
+		if !strings.HasPrefix(port, ":") {
+			port = ":" + port
+		}
+		return []string{port}, nil
+	}
+
+	addrs := splitList(value)
+	if len(addrs) == 0 {
+		return nil, errors.New("no addresses")
+	}
+	for _, addr := range addrs {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return nil, fmt.Errorf("address %q: %w", addr, err)
+		}
+	}
+	return addrs, nil
+}
+
+// shutdown stops accepting requests, drains in-flight requests and the retry
+// queue within the grace period, and then closes the Redis client
+func shutdown(servers []*http.Server, gracePeriod time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), gracePeriod)
+	defer cancel()
+
+	// Announce the shutdown while Redis is still connected
+	publishLifecycleEvent(ctx, lifecycleStopping)
+
+	// Stop taking Socket Mode commands; unacknowledged ones are redelivered by Slack
+	if stopSocketMode != nil {
+		stopSocketMode()
+	}
+
+	// Drain every listener in parallel so they share the grace period
+	var wg sync.WaitGroup
+	for _, server := range servers {
+		wg.Go(func() {
+			if err := server.Shutdown(ctx); err != nil {
+				logError("Error shutting down HTTP server on %s: %v", server.Addr, err)
+			}
+		})
+	}
+	wg.Wait()
+
+	// No more commands are coming, so end the gRPC streams and WebSocket feeds.
+	// Server.Shutdown doesn't wait for hijacked WebSocket connections.
+	if grpcServer != nil {
+		stopGRPCServer(grpcServer)
+	}
+	if enableWebSocket {
+		commandFeed.stop()
+	}
+
+	// Let in-flight response_url posts finish; each is bounded by responseURLTimeout
+	responseURLPosts.Wait()
+
+	// Publish commands already handed to the workers; failures land in the retry queue.
+	// Commands the workers can't reach within DRAIN_TIMEOUT go to the dead-letter sink.
+	if publishQueue != nil {
+		drainCtx, cancelDrain := context.WithTimeout(ctx, drainTimeout)
+		remaining := publishQueue.drain(drainCtx)
+		cancelDrain()
+		if len(remaining) > 0 {
+			if deadLetter != nil {
+				logWarn("Publish queue not drained within %s; writing %d commands to the dead-letter channel", drainTimeout, len(remaining))
+				for _, job := range remaining {
+					deadLetterJob(job, "undrained at shutdown")
+				}
+			} else {
+				logWarn("Publish queue not drained within %s; %d commands will be lost", drainTimeout, len(remaining))
+			}
+		}
+	}
+
+	if publishRetryQueue != nil {
+		publishRetryQueue.stop()
+		if remaining := publishRetryQueue.drain(ctx); remaining > 0 {
+			if deadLetter != nil {
+				logWarn("Shutdown with %d commands still in the retry queue; writing them to the dead-letter channel", remaining)
+				for _, item := range publishRetryQueue.takeAll() {
+					deadLetterItem(item, "unpublished at shutdown")
+				}
+			} else {
+				logWarn("Shutdown with %d commands still in the retry queue; they will be lost", remaining)
+			}
+		}
+	}
+
+	if activePublisher != nil {
+		if err := activePublisher.Close(); err != nil {
+			logError("Error closing publishers: %v", err)
+		}
+	}
+
+	// Flush spans for the commands published above
+	if tracingShutdown != nil {
+		if err := tracingShutdown(ctx); err != nil {
+			logError("Error flushing traces: %v", err)
+		}
 	}
 
-	logInfo("Starting Slack command server on port %s", port)
-	log.Fatal(http.ListenAndServe(port, nil))
+	logInfo("Shutdown complete")
 }
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	commandsReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "slack_commands_received_total",
+		Help: "Total number of Slack commands received.",
+	}, []string{"command"})
+
+	publishFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "slack_publish_failures_total",
+		Help: "Total number of failed publishes to any backend.",
+	})
+
+	malformedRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "slack_malformed_requests_total",
+		Help: "Total number of requests rejected because their form data was unparseable or missing required fields.",
+	}, []string{"reason"})
+
+	duplicateDeliveries = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "slack_duplicate_deliveries_total",
+		Help: "Total number of redelivered commands dropped by DEDUPE.",
+	})
+
+	inFlightRequests = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "slack_command_requests_in_flight",
+		Help: "Number of /command requests currently being handled.",
+	})
+
+	handlerDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "slack_command_handler_duration_seconds",
+		Help:    "Latency of the Slack command handler.",
+		Buckets: prometheus.DefBuckets,
+	})
+)
+
+// metricsCommandLabel controls whether the command name is used as a metric label.
+// Disabling it keeps cardinality bounded when users can invoke arbitrary commands.
+var metricsCommandLabel = true
+
+// commandLabel returns the label value to record for a command
+func commandLabel(command string) string {
+	if !metricsCommandLabel {
+		return ""
+	}
+	return command
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	// natsReconnectWait is the delay between reconnect attempts
+	natsReconnectWait = 2 * time.Second
+
+	// natsFlushTimeout bounds the flush of buffered messages during shutdown
+	natsFlushTimeout = 5 * time.Second
+)
+
+// natsPublisher publishes each command to a NATS subject. The client reconnects
+// forever in the background; messages published while disconnected are buffered
+// by the client and sent once the connection is restored.
+type natsPublisher struct {
+	conn    *nats.Conn
+	subject string
+}
+
+func newNATSPublisher(url, subject string) (*natsPublisher, error) {
+	conn, err := nats.Connect(url,
+		nats.Name(relaySource),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(natsReconnectWait),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				logWarn("Disconnected from NATS: %v", err)
+			}
+		}),
+		nats.ReconnectHandler(func(conn *nats.Conn) {
+			logInfo("Reconnected to NATS at %s", conn.ConnectedUrlRedacted())
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &natsPublisher{conn: conn, subject: subject}, nil
+}
+
+func (p *natsPublisher) Publish(ctx context.Context, key string, payload []byte) error {
+	return p.conn.Publish(p.subject, payload)
+}
+
+// Close flushes buffered messages and closes the connection
+func (p *natsPublisher) Close() error {
+	err := p.conn.FlushTimeout(natsFlushTimeout)
+	p.conn.Close()
+	return err
+}
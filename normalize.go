@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Command name normalizations selectable with NORMALIZE_COMMAND
+const (
+	normalizeLowercase  = "lowercase"
+	normalizeStripSlash = "strip_slash"
+)
+
+// commandNormalization holds the NORMALIZE_COMMAND normalizations applied to
+// the published command name, so consumers don't have to match /Deploy and
+// /deploy alike. Allowlists, routing and responses still see the original.
+var commandNormalization map[string]bool
+
+// parseCommandNormalization parses NORMALIZE_COMMAND, a comma-separated list
+// of normalizations such as "lowercase,strip_slash"
+func parseCommandNormalization(value string) (map[string]bool, error) {
+	names := splitList(value)
+	if len(names) == 0 {
+		return nil, nil
+	}
+	normalizations := make(map[string]bool, len(names))
+	for _, name := range names {
+		name = strings.ToLower(name)
+		if name != normalizeLowercase && name != normalizeStripSlash {
+			return nil, fmt.Errorf("unknown normalization %q, expected %s or %s", name, normalizeLowercase, normalizeStripSlash)
+		}
+		normalizations[name] = true
+	}
+	return normalizations, nil
+}
+
+// normalizedCommand applies the configured normalizations to a command name
+func normalizedCommand(command string) string {
+	if commandNormalization[normalizeLowercase] {
+		command = strings.ToLower(command)
+	}
+	if commandNormalization[normalizeStripSlash] {
+		command = strings.TrimPrefix(command, "/")
+	}
+	return command
+}
+
+// normalizeCommand normalizes the published command name, keeping the name
+// Slack sent in OriginalCommand
+func (e *PublishEnvelope) normalizeCommand() {
+	if len(commandNormalization) == 0 {
+		return
+	}
+	e.OriginalCommand = e.Command.Command
+	e.Command.Command = normalizedCommand(e.Command.Command)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseCommandNormalization(t *testing.T) {
+	got, err := parseCommandNormalization(" Lowercase, strip_slash ")
+	if err != nil || !got[normalizeLowercase] || !got[normalizeStripSlash] {
+		t.Errorf("expected both normalizations, got %v, %v", got, err)
+	}
+	if got, err := parseCommandNormalization(""); got != nil || err != nil {
+		t.Errorf("expected no normalization, got %v, %v", got, err)
+	}
+	if _, err := parseCommandNormalization("lowercase,uppercase"); err == nil || !strings.Contains(err.Error(), `"uppercase"`) {
+		t.Errorf("expected unknown normalization error, got %v", err)
+	}
+}
+
+func TestNormalizedCommand(t *testing.T) {
+	orig := commandNormalization
+	t.Cleanup(func() { commandNormalization = orig })
+
+	tests := []struct {
+		normalize string
+		want      string
+	}{
+		{"", "/Deploy"},
+		{"lowercase", "/deploy"},
+		{"strip_slash", "Deploy"},
+		{"lowercase,strip_slash", "deploy"},
+	}
+	for _, tt := range tests {
+		commandNormalization, _ = parseCommandNormalization(tt.normalize)
+		if got := normalizedCommand("/Deploy"); got != tt.want {
+			t.Errorf("NORMALIZE_COMMAND=%q: expected %q, got %q", tt.normalize, tt.want, got)
+		}
+	}
+}
+
+func TestSlackCommandHandler_NormalizeCommand(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	setSigningSecrets(nil)
+	publishQueue = nil
+	fake := &fakePublisher{}
+	activePublisher = fake
+	orig := commandNormalization
+	t.Cleanup(func() { commandNormalization = orig })
+	commandNormalization, _ = parseCommandNormalization("lowercase,strip_slash")
+	// The allowlist is still matched against the command as sent
+	commandAllowlist = toSet([]string{"/Deploy"})
+
+	w := httptest.NewRecorder()
+	slackCommandHandler(w, httptest.NewRequest(http.MethodPost, "/command", strings.NewReader("command=%2FDeploy&team_id=T1")))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if len(fake.payloads) != 1 {
+		t.Fatalf("expected 1 publish, got %d", len(fake.payloads))
+	}
+	var envelope struct {
+		Command         SlackCommand `json:"command"`
+		OriginalCommand string       `json:"original_command"`
+	}
+	if err := json.Unmarshal(fake.payloads[0], &envelope); err != nil {
+		t.Fatal(err)
+	}
+	if envelope.Command.Command != "deploy" || envelope.OriginalCommand != "/Deploy" {
+		t.Errorf("expected command deploy from /Deploy, got %q from %q", envelope.Command.Command, envelope.OriginalCommand)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+)
+
+// defaultOutputFileBackups is the number of rotated files kept when OUTPUT_FILE_BACKUPS is unset
+const defaultOutputFileBackups = 3
+
+// filePublisher appends each command's JSON envelope to OUTPUT_FILE as one
+// line of newline-delimited JSON, a durable local record that is easy to tail
+// or ship later. The file is rotated by size when OUTPUT_FILE_MAX_MB is set.
+type filePublisher struct {
+	file *rotatingFile
+}
+
+// newFilePublisher opens path for appending. maxBytes of 0 disables rotation.
+func newFilePublisher(path string, maxBytes int64, backups int) (*filePublisher, error) {
+	file, err := openRotatingFile(path, maxBytes, backups)
+	if err != nil {
+		return nil, err
+	}
+	return &filePublisher{file: file}, nil
+}
+
+// Publish appends the payload and its newline in a single locked write, so
+// lines from concurrent publishes never interleave or straddle a rotation
+func (p *filePublisher) Publish(ctx context.Context, key string, payload []byte) error {
+	line := make([]byte, 0, len(payload)+1)
+	line = append(append(line, payload...), '\n')
+	_, err := p.file.Write(line)
+	return err
+}
+
+// Close flushes the file to disk before closing it, so every command
+// published before shutdown survives a crash or power loss afterwards
+func (p *filePublisher) Close() error {
+	if err := p.file.Sync(); err != nil {
+		p.file.Close()
+		return err
+	}
+	return p.file.Close()
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestFilePublisher_AppendsOneLinePerCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "commands.ndjson")
+	if err := os.WriteFile(path, []byte(`{"id":"earlier"}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	publisher, err := newFilePublisher(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Go(func() {
+			payload := fmt.Sprintf(`{"id":"%d","command":{"command":"/deploy"}}`, i)
+			if err := publisher.Publish(context.Background(), "T1", []byte(payload)); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	wg.Wait()
+	if err := publisher.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(readFile(t, path), "\n"), "\n")
+	if len(lines) != 51 {
+		t.Fatalf("expected 51 lines, got %d", len(lines))
+	}
+	if lines[0] != `{"id":"earlier"}` {
+		t.Errorf("expected existing lines to be kept, got %q", lines[0])
+	}
+	for i, line := range lines {
+		if !json.Valid([]byte(line)) {
+			t.Errorf("line %d is not valid JSON: %s", i+1, line)
+		}
+	}
+}
+
+func TestFilePublisher_RotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "commands.ndjson")
+	publisher, err := newFilePublisher(path, 20, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer publisher.Close()
+
+	for _, id := range []string{"first", "second", "third"} {
+		if err := publisher.Publish(context.Background(), "T1", []byte(`{"id":"`+id+`"}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := readFile(t, path); got != `{"id":"third"}`+"\n" {
+		t.Errorf("expected the current file to hold the newest command, got %q", got)
+	}
+	if got := readFile(t, path+".1"); got != `{"id":"second"}`+"\n" {
+		t.Errorf("expected the previous command in .1, got %q", got)
+	}
+	if _, err := os.Stat(path + ".2"); !os.IsNotExist(err) {
+		t.Error("expected no more than 1 backup to be kept")
+	}
+}
+
+func TestNewBackendPublisher_File(t *testing.T) {
+	t.Setenv("OUTPUT_FILE", "")
+	if _, err := newBackendPublisher(backendFile); err == nil {
+		t.Error("expected an error without OUTPUT_FILE")
+	}
+
+	t.Setenv("OUTPUT_FILE", filepath.Join(t.TempDir(), "commands.ndjson"))
+	publisher, err := newBackendPublisher(backendFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer publisher.Close()
+	if _, ok := publisher.(*filePublisher); !ok {
+		t.Errorf("expected a file publisher, got %T", publisher)
+	}
+}
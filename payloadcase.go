@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+)
+
+// JSON key conventions selectable via PAYLOAD_CASE
+const (
+	payloadCaseSnake = "snake"
+	payloadCaseCamel = "camel"
+)
+
+// payloadCase is the key naming convention of published envelopes. Slack's own
+// snake_case is kept by default.
+var payloadCase = payloadCaseSnake
+
+// parsePayloadCase converts a string to a payload case, defaulting to snake
+func parsePayloadCase(value string) string {
+	switch strings.ToLower(value) {
+	case "", payloadCaseSnake:
+		return payloadCaseSnake
+	case payloadCaseCamel:
+		return payloadCaseCamel
+	default:
+		logWarn("Unknown payload case %q, using %s", value, payloadCaseSnake)
+		return payloadCaseSnake
+	}
+}
+
+// marshalEnvelope encodes an envelope for publishing using PAYLOAD_CASE
+func marshalEnvelope(envelope PublishEnvelope) ([]byte, error) {
+	data, err := json.Marshal(envelope)
+	if err != nil || payloadCase != payloadCaseCamel {
+		return data, err
+	}
+	return transformKeys(data, snakeToCamel)
+}
+
+// snakeToCamel converts a snake_case name such as body_sha256 to bodySha256
+func snakeToCamel(name string) string {
+	parts := strings.Split(name, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// camelToSnake converts a camelCase name such as bodySha256 back to body_sha256.
+// snake_case names are returned unchanged.
+func camelToSnake(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if r >= 'A' && r <= 'Z' {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// transformKeys rewrites every object key in a JSON document with rename,
+// leaving values and key order unchanged
+func transformKeys(data []byte, rename func(string) string) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	// Each open container counts the tokens written to it; in an object the
+	// even-numbered tokens are keys
+	type container struct {
+		object bool
+		n      int
+	}
+	var stack []container
+	var buf bytes.Buffer
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if delim, ok := tok.(json.Delim); ok && (delim == '}' || delim == ']') {
+			stack = stack[:len(stack)-1]
+			buf.WriteByte(byte(delim))
+			continue
+		}
+
+		isKey := false
+		if len(stack) > 0 {
+			top := &stack[len(stack)-1]
+			isKey = top.object && top.n%2 == 0
+			if top.object && !isKey {
+				buf.WriteByte(':')
+			} else if top.n > 0 {
+				buf.WriteByte(',')
+			}
+			top.n++
+		}
+
+		switch v := tok.(type) {
+		case json.Delim:
+			stack = append(stack, container{object: v == '{'})
+			buf.WriteByte(byte(v))
+			continue
+		case json.Number:
+			buf.WriteString(v.String())
+			continue
+		case string:
+			if isKey {
+				tok = rename(v)
+			}
+		}
+		encoded, err := json.Marshal(tok)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(encoded)
+	}
+	if len(stack) > 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return buf.Bytes(), nil
+}
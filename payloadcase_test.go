@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnakeToCamel(t *testing.T) {
+	tests := map[string]string{
+		"team_id":         "teamId",
+		"body_sha256":     "bodySha256",
+		"enterprise_name": "enterpriseName",
+		"version":         "version",
+		"traceparent":     "traceparent",
+	}
+	for input, expected := range tests {
+		if got := snakeToCamel(input); got != expected {
+			t.Errorf("snakeToCamel(%q) = %q, want %q", input, got, expected)
+		}
+	}
+}
+
+func TestCamelToSnake(t *testing.T) {
+	tests := map[string]string{
+		"teamId":         "team_id",
+		"bodySha256":     "body_sha256",
+		"enterpriseName": "enterprise_name",
+		"team_id":        "team_id",
+		"version":        "version",
+	}
+	for input, expected := range tests {
+		if got := camelToSnake(input); got != expected {
+			t.Errorf("camelToSnake(%q) = %q, want %q", input, got, expected)
+		}
+	}
+}
+
+func TestTransformKeys_PreservesValuesAndOrder(t *testing.T) {
+	input := `{"request_id":"r_1","args":["a_b",1.50,true,null],"command":{"team_id":"T1","text":"x_y <z>"},"empty_obj":{},"empty_arr":[]}`
+	got, err := transformKeys([]byte(input), snakeToCamel)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := `{"requestId":"r_1","args":["a_b",1.50,true,null],"command":{"teamId":"T1","text":"x_y \u003cz\u003e"},"emptyObj":{},"emptyArr":[]}`
+	if string(got) != expected {
+		t.Errorf("transformKeys() =\n%s\nwant\n%s", got, expected)
+	}
+
+	if _, err := transformKeys([]byte(`{"a":`), snakeToCamel); err == nil {
+		t.Error("expected an error for truncated JSON")
+	}
+}
+
+func TestMarshalEnvelope_Camel(t *testing.T) {
+	orig := payloadCase
+	t.Cleanup(func() { payloadCase = orig })
+	envelope := newPublishEnvelope(SlackCommand{Command: "/deploy", TeamID: "T1", ResponseURL: "https://hooks.slack.com/x"}, "req-1", time.Now())
+
+	payloadCase = parsePayloadCase("camel")
+	data, err := marshalEnvelope(envelope)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, key := range []string{`"requestId":"req-1"`, `"receivedAt":`, `"bodySha256":`, `"teamId":"T1"`, `"responseUrl":`} {
+		if !strings.Contains(string(data), key) {
+			t.Errorf("expected %s in camelCase payload, got %s", key, data)
+		}
+	}
+	if strings.Contains(string(data), "_id") {
+		t.Errorf("expected no snake_case keys, got %s", data)
+	}
+
+	payloadCase = parsePayloadCase("")
+	data, _ = marshalEnvelope(envelope)
+	if !strings.Contains(string(data), `"request_id":"req-1"`) {
+		t.Errorf("expected snake_case keys by default, got %s", data)
+	}
+}
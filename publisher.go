@@ -0,0 +1,372 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Publisher delivers a command's JSON envelope to a message backend. key is
+// the partition key used by backends that preserve ordering per key.
+type Publisher interface {
+	Publish(ctx context.Context, key string, payload []byte) error
+	Close() error
+}
+
+// Backends selectable via BACKEND
+const (
+	backendRedis   = "redis"
+	backendKafka   = "kafka"
+	backendNATS    = "nats"
+	backendSNS     = "sns"
+	backendPubSub  = "pubsub"
+	backendWebhook = "webhook"
+	backendStdout  = "stdout"
+	backendFile    = "file"
+)
+
+// Partition keys selectable via PARTITION_KEY
+const (
+	partitionKeyTeam    = "team_id"
+	partitionKeyChannel = "channel_id"
+	partitionKeyUser    = "user_id"
+)
+
+// activePublisher is the configured backend; nil when publishing is disabled
+var activePublisher Publisher
+
+// backends lists the configured backend names in the order they were given
+var backends = []string{backendRedis}
+
+var partitionKey = partitionKeyTeam
+
+// parseBackend converts a string to a backend name, defaulting to redis
+func parseBackend(value string) string {
+	switch strings.ToLower(value) {
+	case "", backendRedis:
+		return backendRedis
+	case backendKafka:
+		return backendKafka
+	case backendNATS:
+		return backendNATS
+	case backendSNS:
+		return backendSNS
+	case backendPubSub:
+		return backendPubSub
+	case backendWebhook:
+		return backendWebhook
+	case backendStdout:
+		return backendStdout
+	case backendFile:
+		return backendFile
+	default:
+		logWarn("Unknown BACKEND %q, using %s", value, backendRedis)
+		return backendRedis
+	}
+}
+
+// parseBackends converts a comma-separated BACKENDS value to backend names, dropping duplicates
+func parseBackends(value string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, item := range splitList(value) {
+		name := parseBackend(item)
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return []string{backendRedis}
+	}
+	return names
+}
+
+// hasBackend reports whether the named backend is configured
+func hasBackend(name string) bool {
+	for _, b := range backends {
+		if b == name {
+			return true
+		}
+	}
+	return false
+}
+
+// newBackendPublisher creates the publisher for a backend from its environment
+// variables. It returns a nil Publisher, without error, when Redis is unreachable
+// so the relay keeps running without it.
+func newBackendPublisher(name string) (Publisher, error) {
+	switch name {
+	case backendKafka:
+		brokers := splitList(os.Getenv("KAFKA_BROKERS"))
+		topic := os.Getenv("KAFKA_TOPIC")
+		if len(brokers) == 0 || topic == "" {
+			return nil, errors.New("KAFKA_BROKERS and KAFKA_TOPIC are required")
+		}
+		logInfo("Publishing to Kafka topic %s via %s", topic, strings.Join(brokers, ","))
+		return newKafkaPublisher(brokers, topic), nil
+	case backendNATS:
+		natsURL := os.Getenv("NATS_URL")
+		if natsURL == "" {
+			natsURL = "nats://localhost:4222"
+		}
+		subject := os.Getenv("NATS_SUBJECT")
+		if subject == "" {
+			subject = "slack.commands"
+		}
+		publisher, err := newNATSPublisher(natsURL, subject)
+		if err != nil {
+			return nil, err
+		}
+		logInfo("Publishing to NATS subject %s", subject)
+		return publisher, nil
+	case backendSNS:
+		topicARN := os.Getenv("SNS_TOPIC_ARN")
+		if topicARN == "" {
+			return nil, errors.New("SNS_TOPIC_ARN is required")
+		}
+		publisher, err := newSNSPublisher(context.Background(), topicARN)
+		if err != nil {
+			return nil, fmt.Errorf("loading AWS configuration: %w", err)
+		}
+		logInfo("Publishing to SNS topic %s", topicARN)
+		return publisher, nil
+	case backendPubSub:
+		projectID := os.Getenv("GCP_PROJECT_ID")
+		topic := os.Getenv("PUBSUB_TOPIC")
+		if projectID == "" || topic == "" {
+			return nil, errors.New("GCP_PROJECT_ID and PUBSUB_TOPIC are required")
+		}
+		publisher, err := newPubSubPublisher(context.Background(), projectID, topic)
+		if err != nil {
+			return nil, fmt.Errorf("creating Pub/Sub client: %w", err)
+		}
+		logInfo("Publishing to Pub/Sub topic %s in project %s", topic, projectID)
+		return publisher, nil
+	case backendWebhook:
+		if webhookURL == "" {
+			return nil, errors.New("WEBHOOK_URL is required")
+		}
+		return webhookPublisher{}, nil
+	case backendStdout:
+		logInfo("Publishing commands to stdout as newline-delimited JSON")
+		return newStdoutPublisher(), nil
+	case backendFile:
+		path := os.Getenv("OUTPUT_FILE")
+		if path == "" {
+			return nil, errors.New("OUTPUT_FILE is required")
+		}
+		maxBytes := int64(getEnvInt("OUTPUT_FILE_MAX_MB", 0)) << 20
+		publisher, err := newFilePublisher(path, maxBytes, getEnvInt("OUTPUT_FILE_BACKUPS", defaultOutputFileBackups))
+		if err != nil {
+			return nil, fmt.Errorf("opening OUTPUT_FILE: %w", err)
+		}
+		logInfo("Appending commands to %s as newline-delimited JSON", path)
+		return publisher, nil
+	default:
+		redisOpts, err := redisOptionsFromEnv()
+		if err != nil {
+			logError("Invalid Redis configuration: %v", err)
+			logWarn("Redis publishing will be disabled. Service will continue to work without Redis.")
+		} else {
+			setRedisClient(connectRedis(redisOpts))
+		}
+		if getRedisClient() == nil {
+			return nil, nil
+		}
+		if batchSize > 1 {
+			logInfo("Batching Redis publishes: up to %d commands or %s per round trip", batchSize, batchInterval)
+			return newBatchingRedisPublisher(batchSize, batchInterval), nil
+		}
+		return redisPublisher{}, nil
+	}
+}
+
+// MultiPublisher fans each command out to several publishers concurrently. A
+// failure in one publisher doesn't stop the others from receiving the command.
+type MultiPublisher struct {
+	names      []string
+	publishers []Publisher
+}
+
+// withoutBackend returns names with the named backend removed
+func withoutBackend(names []string, name string) []string {
+	return slices.DeleteFunc(slices.Clone(names), func(n string) bool {
+		return strings.EqualFold(n, name)
+	})
+}
+
+// newMultiPublisher combines the named publishers. It returns nil when there
+// are none and the publisher itself when there is only one.
+func newMultiPublisher(names []string, publishers []Publisher) Publisher {
+	switch len(publishers) {
+	case 0:
+		return nil
+	case 1:
+		return publishers[0]
+	default:
+		return &MultiPublisher{names: names, publishers: publishers}
+	}
+}
+
+// Publish publishes to every publisher and returns a *MultiPublishError
+// describing the ones that failed, if any
+func (m *MultiPublisher) Publish(ctx context.Context, key string, payload []byte) error {
+	errs := make([]error, len(m.publishers))
+	var wg sync.WaitGroup
+	for i, publisher := range m.publishers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = publisher.Publish(ctx, key, payload)
+		}()
+	}
+	wg.Wait()
+
+	var failures []PublishFailure
+	for i, err := range errs {
+		if err != nil {
+			failures = append(failures, PublishFailure{Backend: m.names[i], Publisher: m.publishers[i], Err: err})
+		}
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+	return &MultiPublishError{Failures: failures}
+}
+
+// Close closes every publisher, returning the combined errors
+func (m *MultiPublisher) Close() error {
+	var errs []error
+	for i, publisher := range m.publishers {
+		if err := publisher.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", m.names[i], err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// PublishFailure is a single backend's failure within a MultiPublisher publish
+type PublishFailure struct {
+	Backend   string
+	Publisher Publisher
+	Err       error
+}
+
+// MultiPublishError aggregates the failures of a MultiPublisher publish
+type MultiPublishError struct {
+	Failures []PublishFailure
+}
+
+func (e *MultiPublishError) Error() string {
+	parts := make([]string, len(e.Failures))
+	for i, failure := range e.Failures {
+		parts[i] = fmt.Sprintf("%s: %v", failure.Backend, failure.Err)
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (e *MultiPublishError) Unwrap() []error {
+	errs := make([]error, len(e.Failures))
+	for i, failure := range e.Failures {
+		errs[i] = failure.Err
+	}
+	return errs
+}
+
+// failedPublishers returns the failures that need a retry after publisher
+// returned err: just the failed backends for a MultiPublisher, otherwise publisher itself
+func failedPublishers(err error, publisher Publisher) []PublishFailure {
+	var multiErr *MultiPublishError
+	if errors.As(err, &multiErr) {
+		return multiErr.Failures
+	}
+	return []PublishFailure{{Backend: strings.Join(backends, ","), Publisher: publisher, Err: err}}
+}
+
+// retryable reports whether failed publishes to publisher should be queued for
+// retry. Webhook deliveries are only retried when WEBHOOK_RETRY is enabled.
+func retryable(publisher Publisher) bool {
+	if _, ok := publisher.(webhookPublisher); ok {
+		return webhookRetry
+	}
+	return true
+}
+
+// parsePartitionKey converts a string to a partition key field, defaulting to team_id
+func parsePartitionKey(value string) string {
+	switch strings.ToLower(value) {
+	case "", partitionKeyTeam:
+		return partitionKeyTeam
+	case partitionKeyChannel:
+		return partitionKeyChannel
+	case partitionKeyUser:
+		return partitionKeyUser
+	default:
+		logWarn("Unknown partition key %q, using %s", value, partitionKeyTeam)
+		return partitionKeyTeam
+	}
+}
+
+// partitionKeyFor returns the partition key for a command. Every backend
+// receives it; those without a notion of keys ignore it.
+func partitionKeyFor(command SlackCommand) string {
+	switch partitionKey {
+	case partitionKeyChannel:
+		return command.ChannelID
+	case partitionKeyUser:
+		return command.UserID
+	default:
+		return command.TeamID
+	}
+}
+
+type publishMetaKey struct{}
+
+// publishMeta carries the command being published to backends that need more
+// than the payload, e.g. to route by command or attach message attributes
+type publishMeta struct {
+	command   SlackCommand
+	requestID string
+}
+
+// withPublishMeta returns a context carrying the command and its request ID
+func withPublishMeta(ctx context.Context, command SlackCommand, requestID string) context.Context {
+	return context.WithValue(ctx, publishMetaKey{}, publishMeta{command: command, requestID: requestID})
+}
+
+// publishMetaFrom returns the metadata stored by withPublishMeta, or the zero value
+func publishMetaFrom(ctx context.Context) publishMeta {
+	meta, _ := ctx.Value(publishMetaKey{}).(publishMeta)
+	return meta
+}
+
+// redisPublisher publishes to the channel routed for each command using REDIS_MODE
+type redisPublisher struct{}
+
+func (redisPublisher) Publish(ctx context.Context, key string, payload []byte) error {
+	if !redisConnected.Load() {
+		return errRedisUnavailable
+	}
+	command := publishMetaFrom(ctx).command
+	ctx, cancel := context.WithTimeout(ctx, redisPublishTimeout)
+	defer cancel()
+
+	channel := redisChannelFor(command, channelOverrideFrom(ctx))
+	if err := publishToRedis(ctx, channel, key, command, payload, true); err != nil {
+		return fmt.Errorf("redis %s '%s': %w", redisMode, channel, err)
+	}
+	logDebug("Published command to Redis %s: %s", redisMode, channel)
+	return nil
+}
+
+func (redisPublisher) Close() error {
+	if client := getRedisClient(); client != nil {
+		return client.Close()
+	}
+	return nil
+}
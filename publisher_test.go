@@ -0,0 +1,406 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// fakePublisher records published messages and optionally fails
+type fakePublisher struct {
+	keys     []string
+	payloads [][]byte
+	metas    []publishMeta
+	channels []string
+	err      error
+	closed   bool
+}
+
+func (f *fakePublisher) Publish(ctx context.Context, key string, payload []byte) error {
+	f.keys = append(f.keys, key)
+	f.payloads = append(f.payloads, payload)
+	f.metas = append(f.metas, publishMetaFrom(ctx))
+	f.channels = append(f.channels, channelOverrideFrom(ctx))
+	return f.err
+}
+
+func (f *fakePublisher) Close() error {
+	f.closed = true
+	return nil
+}
+
+func TestSlackCommandHandler_PublishesToActivePublisher(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	setSigningSecrets(nil)
+	publisher := &fakePublisher{}
+	activePublisher = publisher
+	partitionKey = partitionKeyTeam
+	t.Cleanup(func() { partitionKey = partitionKeyTeam })
+
+	req := httptest.NewRequest(http.MethodPost, "/command", strings.NewReader("token=secret-token&command=%2Fdeploy&team_id=T1&channel_id=C1"))
+	req.Header.Set("X-Request-ID", "trace-1")
+	w := httptest.NewRecorder()
+	slackCommandHandler(w, req)
+
+	if len(publisher.payloads) != 1 {
+		t.Fatalf("expected 1 publish, got %d", len(publisher.payloads))
+	}
+	if publisher.keys[0] != "T1" {
+		t.Errorf("expected partition key T1, got %q", publisher.keys[0])
+	}
+	if meta := publisher.metas[0]; meta.command.Command != "/deploy" || meta.requestID != "trace-1" {
+		t.Errorf("expected command metadata in context, got %+v", meta)
+	}
+	if !strings.Contains(string(publisher.payloads[0]), `"request_id":"trace-1"`) {
+		t.Errorf("expected envelope payload, got %s", publisher.payloads[0])
+	}
+	bodyHash := sha256.Sum256([]byte("token=secret-token&command=%2Fdeploy&team_id=T1&channel_id=C1"))
+	if !strings.Contains(string(publisher.payloads[0]), `"body_sha256":"`+hex.EncodeToString(bodyHash[:])+`"`) {
+		t.Errorf("expected the SHA-256 of the raw body, got %s", publisher.payloads[0])
+	}
+	if !strings.Contains(string(publisher.payloads[0]), `"token":"[REDACTED]"`) {
+		t.Errorf("expected the verification token to be redacted by default, got %s", publisher.payloads[0])
+	}
+}
+
+func TestSlackCommandHandler_QueuesFailedPublish(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	setSigningSecrets(nil)
+	activePublisher = &fakePublisher{err: errors.New("broker down")}
+	origQueue := publishRetryQueue
+	publishRetryQueue = newRetryQueue(10, nil)
+	t.Cleanup(func() { publishRetryQueue = origQueue })
+
+	req := httptest.NewRequest(http.MethodPost, "/command", strings.NewReader("command=%2Fdeploy&team_id=T1"))
+	w := httptest.NewRecorder()
+	slackCommandHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected publish failures not to fail the request, got %d", w.Code)
+	}
+	item, ok := publishRetryQueue.peek()
+	if !ok {
+		t.Fatal("expected failed publish to be queued for retry")
+	}
+	if item.key != "T1" || item.command.Command != "/deploy" || item.requestID == "" {
+		t.Errorf("unexpected retry item: %+v", item)
+	}
+}
+
+// blockingPublisher blocks until the publish context is cancelled
+type blockingPublisher struct {
+	cancelled chan error
+}
+
+func (b *blockingPublisher) Publish(ctx context.Context, key string, payload []byte) error {
+	<-ctx.Done()
+	b.cancelled <- ctx.Err()
+	return ctx.Err()
+}
+
+func (b *blockingPublisher) Close() error {
+	return nil
+}
+
+func TestSlackCommandHandler_RequestTimeoutCancelsPublish(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	setSigningSecrets(nil)
+	publisher := &blockingPublisher{cancelled: make(chan error, 1)}
+	activePublisher = publisher
+	origQueue := publishRetryQueue
+	publishRetryQueue = nil
+	t.Cleanup(func() { publishRetryQueue = origQueue })
+
+	// The handler keeps running after the timeout response; wait for it before restoring globals
+	handlerDone := make(chan struct{})
+	t.Cleanup(func() { <-handlerDone })
+	handler := withRequestTimeout(func(w http.ResponseWriter, r *http.Request) {
+		defer close(handlerDone)
+		slackCommandHandler(w, r)
+	}, 20*time.Millisecond)
+	req := httptest.NewRequest(http.MethodPost, "/command", strings.NewReader("command=%2Fdeploy&team_id=T1"))
+	w := httptest.NewRecorder()
+	handler(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 on timeout, got %d", w.Code)
+	}
+	select {
+	case err := <-publisher.cancelled:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected publish context deadline to be exceeded, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected in-progress publish to be cancelled")
+	}
+}
+
+func TestRedisPublisher_Unreachable(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	// Nothing listens on port 1, so the publish fails fast
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer client.Close()
+	setRedisClient(client)
+	redisConnected.Store(true)
+
+	ctx := withPublishMeta(context.Background(), SlackCommand{Command: "/deploy"}, "")
+	if err := (redisPublisher{}).Publish(ctx, "T1", []byte("{}")); err == nil {
+		t.Error("expected an error publishing to an unreachable Redis")
+	}
+}
+
+// TestRedisClient_ConcurrentAccess is meant for go test -race: it publishes
+// through the handler while the Redis client and its connection state change.
+func TestRedisClient_ConcurrentAccess(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	setSigningSecrets(nil)
+	activePublisher = redisPublisher{}
+	backends = []string{backendRedis}
+	origQueue := publishRetryQueue
+	publishRetryQueue = nil
+	t.Cleanup(func() { publishRetryQueue = origQueue })
+	// Nothing listens on port 1, so publishes and pings fail fast
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer client.Close()
+
+	stop := make(chan struct{})
+	var toggler sync.WaitGroup
+	toggler.Add(1)
+	go func() {
+		defer toggler.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if i%2 == 0 {
+				setRedisClient(client)
+			} else {
+				setRedisClient(nil)
+			}
+			redisConnected.Store(i%3 != 0)
+		}
+	}()
+
+	var handlers sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		handlers.Add(1)
+		go func() {
+			defer handlers.Done()
+			for j := 0; j < 20; j++ {
+				w := httptest.NewRecorder()
+				slackCommandHandler(w, httptest.NewRequest(http.MethodPost, "/command", strings.NewReader("command=%2Fdeploy&team_id=T1")))
+				if w.Code != http.StatusOK {
+					t.Errorf("expected 200 regardless of Redis state, got %d", w.Code)
+				}
+				healthHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+			}
+		}()
+	}
+	handlers.Wait()
+	close(stop)
+	toggler.Wait()
+}
+
+func TestNewKafkaPublisher(t *testing.T) {
+	publisher := newKafkaPublisher([]string{"kafka1:9092", "kafka2:9092"}, "slack-commands")
+	defer publisher.Close()
+
+	if publisher.writer.Topic != "slack-commands" {
+		t.Errorf("expected topic slack-commands, got %q", publisher.writer.Topic)
+	}
+	if publisher.writer.Addr.String() != "kafka1:9092,kafka2:9092" {
+		t.Errorf("unexpected broker address %q", publisher.writer.Addr.String())
+	}
+}
+
+func TestParseBackend(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"", backendRedis},
+		{"redis", backendRedis},
+		{"kafka", backendKafka},
+		{"KAFKA", backendKafka},
+		{"nats", backendNATS},
+		{"sns", backendSNS},
+		{"pubsub", backendPubSub},
+		{"webhook", backendWebhook},
+		{"stdout", backendStdout},
+		{"file", backendFile},
+		{"rabbitmq", backendRedis},
+	}
+	for _, tt := range tests {
+		if got := parseBackend(tt.input); got != tt.expected {
+			t.Errorf("parseBackend(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestPartitionKeyFor(t *testing.T) {
+	t.Cleanup(func() { partitionKey = partitionKeyTeam })
+	command := SlackCommand{TeamID: "T1", ChannelID: "C1", UserID: "U1"}
+
+	partitionKey = parsePartitionKey("")
+	if got := partitionKeyFor(command); got != "T1" {
+		t.Errorf("expected team_id by default, got %q", got)
+	}
+	partitionKey = parsePartitionKey("channel_id")
+	if got := partitionKeyFor(command); got != "C1" {
+		t.Errorf("expected channel_id, got %q", got)
+	}
+	partitionKey = parsePartitionKey("user_id")
+	if got := partitionKeyFor(command); got != "U1" {
+		t.Errorf("expected user_id, got %q", got)
+	}
+	partitionKey = parsePartitionKey("CHANNEL_ID")
+	if got := partitionKeyFor(command); got != "C1" {
+		t.Errorf("expected channel_id regardless of case, got %q", got)
+	}
+	partitionKey = parsePartitionKey("enterprise_id")
+	if got := partitionKeyFor(command); got != "T1" {
+		t.Errorf("expected fallback to team_id for an unknown field, got %q", got)
+	}
+}
+
+func TestHealthHandler_NonRedisBackend(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	backends = []string{backendKafka}
+	setRedisClient(nil)
+
+	w := httptest.NewRecorder()
+	healthHandler(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `"backend":"kafka"`) {
+		t.Errorf("expected backend in health response, got %s", w.Body.String())
+	}
+}
+
+func TestNewNATSPublisher_RetriesFailedConnect(t *testing.T) {
+	// Nothing listens on port 1; the client should keep retrying in the background
+	publisher, err := newNATSPublisher("nats://127.0.0.1:1", "slack.commands")
+	if err != nil {
+		t.Fatalf("expected startup not to fail while NATS is unreachable: %v", err)
+	}
+	defer publisher.conn.Close()
+
+	if publisher.subject != "slack.commands" {
+		t.Errorf("expected subject slack.commands, got %q", publisher.subject)
+	}
+	if err := publisher.Publish(context.Background(), "T1", []byte("{}")); err != nil {
+		t.Errorf("expected publish to be buffered while reconnecting, got %v", err)
+	}
+}
+
+func TestParseBackends(t *testing.T) {
+	got := parseBackends("redis, webhook,redis")
+	if len(got) != 2 || got[0] != backendRedis || got[1] != backendWebhook {
+		t.Errorf("expected [redis webhook], got %v", got)
+	}
+	if got := parseBackends(" , "); len(got) != 1 || got[0] != backendRedis {
+		t.Errorf("expected redis for an empty list, got %v", got)
+	}
+}
+
+func TestWithoutBackend(t *testing.T) {
+	names := []string{backendRedis, backendWebhook}
+	if got := withoutBackend(names, backendRedis); !reflect.DeepEqual(got, []string{backendWebhook}) {
+		t.Errorf("expected only webhook to remain, got %q", got)
+	}
+	if got := withoutBackend([]string{backendRedis}, backendRedis); len(got) != 0 {
+		t.Errorf("expected no backends, got %q", got)
+	}
+	if !reflect.DeepEqual(names, []string{backendRedis, backendWebhook}) {
+		t.Errorf("expected the input to be left unchanged, got %q", names)
+	}
+}
+
+func TestNewMultiPublisher(t *testing.T) {
+	if newMultiPublisher(nil, nil) != nil {
+		t.Error("expected nil publisher when no backends are available")
+	}
+	single := &fakePublisher{}
+	if got := newMultiPublisher([]string{"redis"}, []Publisher{single}); got != single {
+		t.Error("expected a single publisher to be used directly")
+	}
+}
+
+func TestMultiPublisher_FansOutDespiteFailures(t *testing.T) {
+	ok := &fakePublisher{}
+	failing := &fakePublisher{err: errors.New("webhook returned 502")}
+	multi := newMultiPublisher([]string{"redis", "webhook"}, []Publisher{ok, failing})
+
+	err := multi.Publish(context.Background(), "T1", []byte("{}"))
+	if len(ok.payloads) != 1 || len(failing.payloads) != 1 {
+		t.Fatalf("expected every publisher to receive the command, got %d and %d", len(ok.payloads), len(failing.payloads))
+	}
+	if err == nil || !strings.Contains(err.Error(), "webhook: webhook returned 502") {
+		t.Fatalf("expected aggregated webhook error, got %v", err)
+	}
+
+	failed := failedPublishers(err, multi)
+	if len(failed) != 1 || failed[0].Publisher != failing || failed[0].Backend != "webhook" {
+		t.Errorf("expected only the failing publisher to be retried, got %v", failed)
+	}
+
+	if err := multi.Close(); err != nil {
+		t.Errorf("unexpected close error: %v", err)
+	}
+	if !ok.closed || !failing.closed {
+		t.Error("expected every publisher to be closed")
+	}
+}
+
+func TestSlackCommandHandler_RetriesOnlyFailedBackends(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	setSigningSecrets(nil)
+	ok := &fakePublisher{}
+	failing := &fakePublisher{err: errors.New("broker down")}
+	activePublisher = newMultiPublisher([]string{"redis", "kafka"}, []Publisher{ok, failing})
+	origQueue := publishRetryQueue
+	publishRetryQueue = newRetryQueue(10, nil)
+	t.Cleanup(func() { publishRetryQueue = origQueue })
+
+	req := httptest.NewRequest(http.MethodPost, "/command", strings.NewReader("command=%2Fdeploy&team_id=T1"))
+	w := httptest.NewRecorder()
+	slackCommandHandler(w, req)
+
+	if publishRetryQueue.len() != 1 {
+		t.Fatalf("expected 1 queued retry, got %d", publishRetryQueue.len())
+	}
+	if item, _ := publishRetryQueue.peek(); item.target != failing {
+		t.Errorf("expected retry to target the failed backend, got %v", item.target)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	origRetry := webhookRetry
+	t.Cleanup(func() { webhookRetry = origRetry })
+
+	webhookRetry = false
+	if retryable(webhookPublisher{}) {
+		t.Error("expected webhook deliveries not to be retried by default")
+	}
+	webhookRetry = true
+	if !retryable(webhookPublisher{}) {
+		t.Error("expected webhook deliveries to be retried with WEBHOOK_RETRY")
+	}
+	if !retryable(redisPublisher{}) {
+		t.Error("expected Redis publishes to be retried")
+	}
+}
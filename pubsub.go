@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/pubsub/v2"
+	"google.golang.org/api/option"
+)
+
+// pubsubPublishTimeout bounds each Pub/Sub publish, including waiting for the server ack
+const pubsubPublishTimeout = 5 * time.Second
+
+// pubsubPublisher publishes each command to a Google Cloud Pub/Sub topic with
+// the command name and team ID as message attributes for subscriber filtering
+type pubsubPublisher struct {
+	client    *pubsub.Client
+	publisher *pubsub.Publisher
+}
+
+// newPubSubPublisher creates a publisher authenticated via Application Default Credentials
+func newPubSubPublisher(ctx context.Context, projectID, topic string, opts ...option.ClientOption) (*pubsubPublisher, error) {
+	client, err := pubsub.NewClient(ctx, projectID, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &pubsubPublisher{client: client, publisher: client.Publisher(topic)}, nil
+}
+
+func (p *pubsubPublisher) Publish(ctx context.Context, key string, payload []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, pubsubPublishTimeout)
+	defer cancel()
+
+	command := publishMetaFrom(ctx).command
+	result := p.publisher.Publish(ctx, &pubsub.Message{
+		Data: payload,
+		Attributes: map[string]string{
+			"command": command.Command,
+			"team_id": command.TeamID,
+		},
+	})
+	// Wait for the ack so failures follow the same logging and retry path as other backends
+	_, err := result.Get(ctx)
+	return err
+}
+
+// Close flushes buffered messages and closes the client
+func (p *pubsubPublisher) Close() error {
+	p.publisher.Stop()
+	return p.client.Close()
+}
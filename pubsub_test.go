@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
+	"cloud.google.com/go/pubsub/v2/pstest"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestPubSubPublisher_Publish(t *testing.T) {
+	ctx := context.Background()
+	server := pstest.NewServer()
+	defer server.Close()
+
+	conn, err := grpc.NewClient(server.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer conn.Close()
+
+	publisher, err := newPubSubPublisher(ctx, "test-project", "slack-commands", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := publisher.client.TopicAdminClient.CreateTopic(ctx, &pubsubpb.Topic{Name: "projects/test-project/topics/slack-commands"}); err != nil {
+		t.Fatalf("unexpected error creating topic: %v", err)
+	}
+
+	publishCtx := withPublishMeta(ctx, SlackCommand{Command: "/deploy", TeamID: "T1"}, "")
+	if err := publisher.Publish(publishCtx, "T1", []byte(`{"id":"1"}`)); err != nil {
+		t.Fatalf("unexpected publish error: %v", err)
+	}
+	if err := publisher.Close(); err != nil {
+		t.Errorf("unexpected close error: %v", err)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(messages))
+	}
+	if string(messages[0].Data) != `{"id":"1"}` {
+		t.Errorf("expected payload as message data, got %s", messages[0].Data)
+	}
+	if messages[0].Attributes["command"] != "/deploy" || messages[0].Attributes["team_id"] != "T1" {
+		t.Errorf("expected command and team_id attributes, got %v", messages[0].Attributes)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// rateLimiterIdleTTL is how long a key's limiter is kept after its last request
+	rateLimiterIdleTTL = 10 * time.Minute
+
+	// defaultRateLimitMessage is shown to users who exceed the rate limit
+	defaultRateLimitMessage = "You're sending commands too quickly. Please slow down and try again in a moment."
+)
+
+// Rate limit keys selectable via RATE_LIMIT_KEY
+const (
+	rateLimitKeyUser = "user_id"
+	rateLimitKeyTeam = "team_id"
+)
+
+// rateLimiter is a set of token-bucket limiters, one per key. Limiters that
+// have been idle for longer than idleTTL are evicted to bound memory.
+type rateLimiter struct {
+	mu        sync.Mutex
+	limiters  map[string]*limiterEntry
+	limit     rate.Limit
+	burst     int
+	idleTTL   time.Duration
+	lastSweep time.Time
+}
+
+type limiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+var commandRateLimiter *rateLimiter
+var rateLimitKey = rateLimitKeyUser
+
+func newRateLimiter(rps float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		limiters:  make(map[string]*limiterEntry),
+		limit:     rate.Limit(rps),
+		burst:     burst,
+		idleTTL:   rateLimiterIdleTTL,
+		lastSweep: time.Now(),
+	}
+}
+
+// allow reports whether a request for key may proceed now
+func (l *rateLimiter) allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) > l.idleTTL {
+		for k, entry := range l.limiters {
+			if now.Sub(entry.lastSeen) > l.idleTTL {
+				delete(l.limiters, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	entry, ok := l.limiters[key]
+	if !ok {
+		entry = &limiterEntry{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.limiters[key] = entry
+	}
+	entry.lastSeen = now
+	return entry.limiter.AllowN(now, 1)
+}
+
+// parseRateLimitKey converts a string to a rate limit key, defaulting to user_id
+func parseRateLimitKey(value string) string {
+	switch strings.ToLower(value) {
+	case "", rateLimitKeyUser, "user":
+		return rateLimitKeyUser
+	case rateLimitKeyTeam, "team":
+		return rateLimitKeyTeam
+	default:
+		logWarn("Unknown RATE_LIMIT_KEY %q, using %s", value, rateLimitKeyUser)
+		return rateLimitKeyUser
+	}
+}
+
+// rateLimitKeyFor returns the value a command is rate limited by
+func rateLimitKeyFor(command SlackCommand) string {
+	if rateLimitKey == rateLimitKeyTeam {
+		return command.TeamID
+	}
+	return command.UserID
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_AllowsBurstThenLimits(t *testing.T) {
+	limiter := newRateLimiter(0.001, 2)
+
+	if !limiter.allow("U1") || !limiter.allow("U1") {
+		t.Fatal("expected burst of 2 to be allowed")
+	}
+	if limiter.allow("U1") {
+		t.Error("expected third request to be limited")
+	}
+	if !limiter.allow("U2") {
+		t.Error("expected a different key to have its own bucket")
+	}
+}
+
+func TestRateLimiter_EvictsIdleEntries(t *testing.T) {
+	limiter := newRateLimiter(1, 1)
+	limiter.idleTTL = time.Millisecond
+
+	limiter.allow("U1")
+	time.Sleep(5 * time.Millisecond)
+	limiter.allow("U2")
+
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	if _, ok := limiter.limiters["U1"]; ok {
+		t.Error("expected idle limiter to be evicted")
+	}
+	if _, ok := limiter.limiters["U2"]; !ok {
+		t.Error("expected active limiter to be kept")
+	}
+}
+
+func TestParseRateLimitKey(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"", rateLimitKeyUser},
+		{"user_id", rateLimitKeyUser},
+		{"team_id", rateLimitKeyTeam},
+		{"team", rateLimitKeyTeam},
+		{"TEAM_ID", rateLimitKeyTeam},
+		{"channel_id", rateLimitKeyUser},
+	}
+	for _, tt := range tests {
+		if got := parseRateLimitKey(tt.input); got != tt.expected {
+			t.Errorf("parseRateLimitKey(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
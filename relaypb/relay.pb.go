@@ -0,0 +1,347 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: relay.proto
+
+package relaypb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type SubscribeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubscribeRequest) Reset() {
+	*x = SubscribeRequest{}
+	mi := &file_relay_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscribeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscribeRequest) ProtoMessage() {}
+
+func (x *SubscribeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_relay_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscribeRequest.ProtoReflect.Descriptor instead.
+func (*SubscribeRequest) Descriptor() ([]byte, []int) {
+	return file_relay_proto_rawDescGZIP(), []int{0}
+}
+
+// Command mirrors the published envelope, after REDACT_FIELDS is applied
+type Command struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Id             string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	RequestId      string                 `protobuf:"bytes,2,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	ReceivedAt     *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=received_at,json=receivedAt,proto3" json:"received_at,omitempty"`
+	Token          string                 `protobuf:"bytes,4,opt,name=token,proto3" json:"token,omitempty"`
+	TeamId         string                 `protobuf:"bytes,5,opt,name=team_id,json=teamId,proto3" json:"team_id,omitempty"`
+	TeamDomain     string                 `protobuf:"bytes,6,opt,name=team_domain,json=teamDomain,proto3" json:"team_domain,omitempty"`
+	ChannelId      string                 `protobuf:"bytes,7,opt,name=channel_id,json=channelId,proto3" json:"channel_id,omitempty"`
+	ChannelName    string                 `protobuf:"bytes,8,opt,name=channel_name,json=channelName,proto3" json:"channel_name,omitempty"`
+	UserId         string                 `protobuf:"bytes,9,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	UserName       string                 `protobuf:"bytes,10,opt,name=user_name,json=userName,proto3" json:"user_name,omitempty"`
+	Command        string                 `protobuf:"bytes,11,opt,name=command,proto3" json:"command,omitempty"`
+	Text           string                 `protobuf:"bytes,12,opt,name=text,proto3" json:"text,omitempty"`
+	ResponseUrl    string                 `protobuf:"bytes,13,opt,name=response_url,json=responseUrl,proto3" json:"response_url,omitempty"`
+	TriggerId      string                 `protobuf:"bytes,14,opt,name=trigger_id,json=triggerId,proto3" json:"trigger_id,omitempty"`
+	ApiAppId       string                 `protobuf:"bytes,15,opt,name=api_app_id,json=apiAppId,proto3" json:"api_app_id,omitempty"`
+	EnterpriseId   string                 `protobuf:"bytes,16,opt,name=enterprise_id,json=enterpriseId,proto3" json:"enterprise_id,omitempty"`
+	EnterpriseName string                 `protobuf:"bytes,17,opt,name=enterprise_name,json=enterpriseName,proto3" json:"enterprise_name,omitempty"`
+	Args           []string               `protobuf:"bytes,18,rep,name=args,proto3" json:"args,omitempty"`
+	TextTruncated  bool                   `protobuf:"varint,19,opt,name=text_truncated,json=textTruncated,proto3" json:"text_truncated,omitempty"`
+	// original_command is the command name as sent, when NORMALIZE_COMMAND is set
+	OriginalCommand string `protobuf:"bytes,20,opt,name=original_command,json=originalCommand,proto3" json:"original_command,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Command) Reset() {
+	*x = Command{}
+	mi := &file_relay_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Command) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Command) ProtoMessage() {}
+
+func (x *Command) ProtoReflect() protoreflect.Message {
+	mi := &file_relay_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Command.ProtoReflect.Descriptor instead.
+func (*Command) Descriptor() ([]byte, []int) {
+	return file_relay_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Command) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Command) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *Command) GetReceivedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ReceivedAt
+	}
+	return nil
+}
+
+func (x *Command) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *Command) GetTeamId() string {
+	if x != nil {
+		return x.TeamId
+	}
+	return ""
+}
+
+func (x *Command) GetTeamDomain() string {
+	if x != nil {
+		return x.TeamDomain
+	}
+	return ""
+}
+
+func (x *Command) GetChannelId() string {
+	if x != nil {
+		return x.ChannelId
+	}
+	return ""
+}
+
+func (x *Command) GetChannelName() string {
+	if x != nil {
+		return x.ChannelName
+	}
+	return ""
+}
+
+func (x *Command) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *Command) GetUserName() string {
+	if x != nil {
+		return x.UserName
+	}
+	return ""
+}
+
+func (x *Command) GetCommand() string {
+	if x != nil {
+		return x.Command
+	}
+	return ""
+}
+
+func (x *Command) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *Command) GetResponseUrl() string {
+	if x != nil {
+		return x.ResponseUrl
+	}
+	return ""
+}
+
+func (x *Command) GetTriggerId() string {
+	if x != nil {
+		return x.TriggerId
+	}
+	return ""
+}
+
+func (x *Command) GetApiAppId() string {
+	if x != nil {
+		return x.ApiAppId
+	}
+	return ""
+}
+
+func (x *Command) GetEnterpriseId() string {
+	if x != nil {
+		return x.EnterpriseId
+	}
+	return ""
+}
+
+func (x *Command) GetEnterpriseName() string {
+	if x != nil {
+		return x.EnterpriseName
+	}
+	return ""
+}
+
+func (x *Command) GetArgs() []string {
+	if x != nil {
+		return x.Args
+	}
+	return nil
+}
+
+func (x *Command) GetTextTruncated() bool {
+	if x != nil {
+		return x.TextTruncated
+	}
+	return false
+}
+
+func (x *Command) GetOriginalCommand() string {
+	if x != nil {
+		return x.OriginalCommand
+	}
+	return ""
+}
+
+var File_relay_proto protoreflect.FileDescriptor
+
+const file_relay_proto_rawDesc = "" +
+	"\n" +
+	"\vrelay.proto\x12\rslackrelay.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x12\n" +
+	"\x10SubscribeRequest\"\xff\x04\n" +
+	"\aCommand\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x02 \x01(\tR\trequestId\x12;\n" +
+	"\vreceived_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"receivedAt\x12\x14\n" +
+	"\x05token\x18\x04 \x01(\tR\x05token\x12\x17\n" +
+	"\ateam_id\x18\x05 \x01(\tR\x06teamId\x12\x1f\n" +
+	"\vteam_domain\x18\x06 \x01(\tR\n" +
+	"teamDomain\x12\x1d\n" +
+	"\n" +
+	"channel_id\x18\a \x01(\tR\tchannelId\x12!\n" +
+	"\fchannel_name\x18\b \x01(\tR\vchannelName\x12\x17\n" +
+	"\auser_id\x18\t \x01(\tR\x06userId\x12\x1b\n" +
+	"\tuser_name\x18\n" +
+	" \x01(\tR\buserName\x12\x18\n" +
+	"\acommand\x18\v \x01(\tR\acommand\x12\x12\n" +
+	"\x04text\x18\f \x01(\tR\x04text\x12!\n" +
+	"\fresponse_url\x18\r \x01(\tR\vresponseUrl\x12\x1d\n" +
+	"\n" +
+	"trigger_id\x18\x0e \x01(\tR\ttriggerId\x12\x1c\n" +
+	"\n" +
+	"api_app_id\x18\x0f \x01(\tR\bapiAppId\x12#\n" +
+	"\renterprise_id\x18\x10 \x01(\tR\fenterpriseId\x12'\n" +
+	"\x0fenterprise_name\x18\x11 \x01(\tR\x0eenterpriseName\x12\x12\n" +
+	"\x04args\x18\x12 \x03(\tR\x04args\x12%\n" +
+	"\x0etext_truncated\x18\x13 \x01(\bR\rtextTruncated\x12)\n" +
+	"\x10original_command\x18\x14 \x01(\tR\x0foriginalCommand2V\n" +
+	"\fCommandRelay\x12F\n" +
+	"\tSubscribe\x12\x1f.slackrelay.v1.SubscribeRequest\x1a\x16.slackrelay.v1.Command0\x01B3Z1github.com/its-the-vibe/SlackCommandRelay/relaypbb\x06proto3"
+
+var (
+	file_relay_proto_rawDescOnce sync.Once
+	file_relay_proto_rawDescData []byte
+)
+
+func file_relay_proto_rawDescGZIP() []byte {
+	file_relay_proto_rawDescOnce.Do(func() {
+		file_relay_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_relay_proto_rawDesc), len(file_relay_proto_rawDesc)))
+	})
+	return file_relay_proto_rawDescData
+}
+
+var file_relay_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_relay_proto_goTypes = []any{
+	(*SubscribeRequest)(nil),      // 0: slackrelay.v1.SubscribeRequest
+	(*Command)(nil),               // 1: slackrelay.v1.Command
+	(*timestamppb.Timestamp)(nil), // 2: google.protobuf.Timestamp
+}
+var file_relay_proto_depIdxs = []int32{
+	2, // 0: slackrelay.v1.Command.received_at:type_name -> google.protobuf.Timestamp
+	0, // 1: slackrelay.v1.CommandRelay.Subscribe:input_type -> slackrelay.v1.SubscribeRequest
+	1, // 2: slackrelay.v1.CommandRelay.Subscribe:output_type -> slackrelay.v1.Command
+	2, // [2:3] is the sub-list for method output_type
+	1, // [1:2] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_relay_proto_init() }
+func file_relay_proto_init() {
+	if File_relay_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_relay_proto_rawDesc), len(file_relay_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_relay_proto_goTypes,
+		DependencyIndexes: file_relay_proto_depIdxs,
+		MessageInfos:      file_relay_proto_msgTypes,
+	}.Build()
+	File_relay_proto = out.File
+	file_relay_proto_goTypes = nil
+	file_relay_proto_depIdxs = nil
+}
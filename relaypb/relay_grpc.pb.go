@@ -0,0 +1,134 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: relay.proto
+
+package relaypb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	CommandRelay_Subscribe_FullMethodName = "/slackrelay.v1.CommandRelay/Subscribe"
+)
+
+// CommandRelayClient is the client API for CommandRelay service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// CommandRelay streams Slack commands to downstream consumers as the relay
+// receives them
+type CommandRelayClient interface {
+	// Subscribe streams every command received after the call is made. Slow
+	// subscribers miss commands rather than hold up the relay.
+	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Command], error)
+}
+
+type commandRelayClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCommandRelayClient(cc grpc.ClientConnInterface) CommandRelayClient {
+	return &commandRelayClient{cc}
+}
+
+func (c *commandRelayClient) Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Command], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &CommandRelay_ServiceDesc.Streams[0], CommandRelay_Subscribe_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SubscribeRequest, Command]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type CommandRelay_SubscribeClient = grpc.ServerStreamingClient[Command]
+
+// CommandRelayServer is the server API for CommandRelay service.
+// All implementations must embed UnimplementedCommandRelayServer
+// for forward compatibility.
+//
+// CommandRelay streams Slack commands to downstream consumers as the relay
+// receives them
+type CommandRelayServer interface {
+	// Subscribe streams every command received after the call is made. Slow
+	// subscribers miss commands rather than hold up the relay.
+	Subscribe(*SubscribeRequest, grpc.ServerStreamingServer[Command]) error
+	mustEmbedUnimplementedCommandRelayServer()
+}
+
+// UnimplementedCommandRelayServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedCommandRelayServer struct{}
+
+func (UnimplementedCommandRelayServer) Subscribe(*SubscribeRequest, grpc.ServerStreamingServer[Command]) error {
+	return status.Error(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (UnimplementedCommandRelayServer) mustEmbedUnimplementedCommandRelayServer() {}
+func (UnimplementedCommandRelayServer) testEmbeddedByValue()                      {}
+
+// UnsafeCommandRelayServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CommandRelayServer will
+// result in compilation errors.
+type UnsafeCommandRelayServer interface {
+	mustEmbedUnimplementedCommandRelayServer()
+}
+
+func RegisterCommandRelayServer(s grpc.ServiceRegistrar, srv CommandRelayServer) {
+	// If the following call panics, it indicates UnimplementedCommandRelayServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&CommandRelay_ServiceDesc, srv)
+}
+
+func _CommandRelay_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CommandRelayServer).Subscribe(m, &grpc.GenericServerStream[SubscribeRequest, Command]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type CommandRelay_SubscribeServer = grpc.ServerStreamingServer[Command]
+
+// CommandRelay_ServiceDesc is the grpc.ServiceDesc for CommandRelay service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var CommandRelay_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "slackrelay.v1.CommandRelay",
+	HandlerType: (*CommandRelayServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Subscribe",
+			Handler:       _CommandRelay_Subscribe_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "relay.proto",
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// adminTokenHeader authenticates operator endpoints such as /replay
+	adminTokenHeader = "X-Admin-Token"
+
+	// replayRPS and replayBurst bound how fast commands can be replayed, so a
+	// leaked admin token or a runaway script can't flood the backends
+	replayRPS   = 1
+	replayBurst = 5
+)
+
+// adminToken is the ADMIN_TOKEN operators send in adminTokenHeader. The admin
+// endpoints are not registered when it is empty.
+var adminToken []byte
+
+// replayLimiter rate limits /replay across all callers
+var replayLimiter = rate.NewLimiter(replayRPS, replayBurst)
+
+// verifyAdminToken reports whether the request carries the admin token,
+// compared in constant time
+func verifyAdminToken(r *http.Request) bool {
+	return validAdminToken(r.Header.Get(adminTokenHeader))
+}
+
+// validAdminToken reports whether token is the configured admin token
+func validAdminToken(token string) bool {
+	return len(adminToken) > 0 && hmac.Equal(adminToken, []byte(token))
+}
+
+// parseReplayCommand accepts either a bare SlackCommand or a previously
+// published PublishEnvelope and returns the command to replay. Keys are
+// converted back to snake_case first, so envelopes published with
+// PAYLOAD_CASE=camel are accepted too.
+func parseReplayCommand(body []byte) (SlackCommand, error) {
+	body, err := transformKeys(body, camelToSnake)
+	if err != nil {
+		return SlackCommand{}, err
+	}
+	var probe struct {
+		Command json.RawMessage `json:"command"`
+	}
+	if err := json.Unmarshal(body, &probe); err != nil {
+		return SlackCommand{}, err
+	}
+	// An envelope nests the command as an object; a SlackCommand has a string
+	if len(probe.Command) > 0 && probe.Command[0] == '{' {
+		var command SlackCommand
+		if err := json.Unmarshal(probe.Command, &command); err != nil {
+			return SlackCommand{}, err
+		}
+		return command, nil
+	}
+	var command SlackCommand
+	if err := json.Unmarshal(body, &command); err != nil {
+		return SlackCommand{}, err
+	}
+	return command, nil
+}
+
+// replayHandler re-publishes a stored command so operators can reproduce
+// consumer issues without a real Slack request. It skips Slack signature
+// checks and is authenticated by ADMIN_TOKEN instead.
+func replayHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	requestID := requestIDFor(r)
+	w.Header().Set(requestIDHeader, requestID)
+
+	if !verifyAdminToken(r) {
+		logRequest(WARN, requestID, "Rejected /replay request with an invalid admin token from %s", r.RemoteAddr)
+		http.Error(w, "Invalid admin token", http.StatusUnauthorized)
+		return
+	}
+	if !replayLimiter.Allow() {
+		logRequest(WARN, requestID, "Rate limited /replay request from %s", r.RemoteAddr)
+		http.Error(w, "Too many replays", http.StatusTooManyRequests)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusBadRequest)
+		return
+	}
+	command, err := parseReplayCommand(body)
+	if err != nil {
+		http.Error(w, "Invalid JSON command", http.StatusBadRequest)
+		return
+	}
+	if missing := missingRequiredFields(command); len(missing) > 0 {
+		http.Error(w, "Missing required fields: "+strings.Join(missing, ", "), http.StatusBadRequest)
+		return
+	}
+
+	ctx, span := tracer().Start(r.Context(), "slack.replay")
+	defer span.End()
+
+	bodyHash := sha256.Sum256(body)
+	envelope := newPublishEnvelope(command, requestID, time.Now())
+	envelope.BodySHA256 = hex.EncodeToString(bodyHash[:])
+	envelope.Replay = true
+	envelope.Trace = traceCarrier(ctx)
+	envelope.redact(redactFields)
+	envelope.normalizeCommand()
+	payload, err := marshalEnvelope(envelope)
+	if err != nil {
+		logRequest(ERROR, requestID, "Error marshaling envelope to JSON: %v", err)
+		http.Error(w, "Error encoding command", http.StatusInternalServerError)
+		return
+	}
+
+	logRequest(WARN, requestID, "[REPLAY] Replaying command %s for team %s requested by %s", command.Command, command.TeamID, r.RemoteAddr)
+	if dryRun {
+		logRequest(INFO, requestID, "[DRY RUN] would publish to %s: %s", publishTarget(command, ""), payload)
+	} else if activePublisher != nil {
+		published := redactCommand(command, redactFields)
+		publishCommand(ctx, publishJob{key: partitionKeyFor(published), command: published, requestID: requestID, payload: payload, span: span.SpanContext()})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"id": envelope.ID, "request_id": requestID})
+}
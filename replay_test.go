@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestParseReplayCommand(t *testing.T) {
+	command, err := parseReplayCommand([]byte(`{"command":"/deploy","team_id":"T1","text":"api"}`))
+	if err != nil || command.Command != "/deploy" || command.Text != "api" {
+		t.Errorf("expected bare command to parse, got %+v, %v", command, err)
+	}
+
+	command, err = parseReplayCommand([]byte(`{"version":1,"id":"abc","received_at":"2024-01-02T03:04:05Z","command":{"command":"/status","team_id":"T2"}}`))
+	if err != nil || command.Command != "/status" || command.TeamID != "T2" {
+		t.Errorf("expected envelope command to parse, got %+v, %v", command, err)
+	}
+
+	if _, err := parseReplayCommand([]byte(`not json`)); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
+
+func TestParseReplayCommand_CamelEnvelope(t *testing.T) {
+	orig := payloadCase
+	t.Cleanup(func() { payloadCase = orig })
+	payloadCase = payloadCaseCamel
+	published := SlackCommand{Command: "/deploy", TeamID: "T1", ChannelID: "C1", UserID: "U1", UserName: "alice", Text: "api production", ResponseURL: "https://hooks.slack.com/x"}
+
+	data, err := marshalEnvelope(newPublishEnvelope(published, "req-1", time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	command, err := parseReplayCommand(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if command != published {
+		t.Errorf("expected the published command back, got %+v", command)
+	}
+}
+
+func TestReplayHandler(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	origToken, origLimiter := adminToken, replayLimiter
+	t.Cleanup(func() { adminToken, replayLimiter = origToken, origLimiter })
+	adminToken = []byte("admin-secret")
+	replayLimiter = rate.NewLimiter(rate.Inf, 0)
+	fake := &fakePublisher{}
+	activePublisher = fake
+
+	send := func(token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/replay", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set(adminTokenHeader, token)
+		}
+		w := httptest.NewRecorder()
+		replayHandler(w, req)
+		return w
+	}
+
+	if w := send("", `{"command":"/deploy","team_id":"T1"}`); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without a token, got %d", w.Code)
+	}
+	if w := send("wrong", `{"command":"/deploy","team_id":"T1"}`); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 with a wrong token, got %d", w.Code)
+	}
+	if w := send("admin-secret", `{"command":"/deploy"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a command without team_id, got %d", w.Code)
+	}
+	if len(fake.payloads) != 0 {
+		t.Fatalf("expected nothing to be published yet, got %d", len(fake.payloads))
+	}
+
+	w := send("admin-secret", `{"command":{"command":"/deploy","team_id":"T1","token":"leaked"}}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(fake.payloads) != 1 {
+		t.Fatalf("expected 1 publish, got %d", len(fake.payloads))
+	}
+	var envelope struct {
+		Replay  bool         `json:"replay"`
+		Command SlackCommand `json:"command"`
+	}
+	if err := json.Unmarshal(fake.payloads[0], &envelope); err != nil {
+		t.Fatalf("invalid published JSON: %v", err)
+	}
+	if !envelope.Replay || envelope.Command.Command != "/deploy" {
+		t.Errorf("expected a replayed /deploy envelope, got %+v", envelope)
+	}
+	if envelope.Command.Token != redactedValue {
+		t.Errorf("expected the token to be redacted, got %q", envelope.Command.Token)
+	}
+}
+
+func TestReplayHandler_RateLimited(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	origToken, origLimiter := adminToken, replayLimiter
+	t.Cleanup(func() { adminToken, replayLimiter = origToken, origLimiter })
+	adminToken = []byte("admin-secret")
+	replayLimiter = rate.NewLimiter(rate.Every(time.Hour), 1)
+	activePublisher = &fakePublisher{}
+
+	codes := make([]int, 2)
+	for i := range codes {
+		req := httptest.NewRequest(http.MethodPost, "/replay", strings.NewReader(`{"command":"/deploy","team_id":"T1"}`))
+		req.Header.Set(adminTokenHeader, "admin-secret")
+		w := httptest.NewRecorder()
+		replayHandler(w, req)
+		codes[i] = w.Code
+	}
+	if codes[0] != http.StatusAccepted || codes[1] != http.StatusTooManyRequests {
+		t.Errorf("expected 202 then 429, got %v", codes)
+	}
+}
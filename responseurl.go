@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// responseURLTimeout bounds each POST to a Slack response_url
+	responseURLTimeout = 5 * time.Second
+
+	// defaultResponseURLMessage is posted to response_url when RESPONSE_URL_MESSAGE is unset
+	defaultResponseURLMessage = "Working on it…"
+)
+
+// SlackMessage is a message posted to a command's response_url. Slack accepts
+// the same fields as the immediate acknowledgement.
+type SlackMessage = SlackResponse
+
+// useResponseURL posts responseURLMessage to each command's response_url while the ack returns
+var useResponseURL bool
+var responseURLMessage = defaultResponseURLMessage
+
+var responseURLClient = &http.Client{Timeout: responseURLTimeout}
+
+// responseURLPosts tracks in-flight posts so shutdown can wait for them
+var responseURLPosts sync.WaitGroup
+
+// PostResponseURL posts msg to a Slack response_url, the deferred-response
+// endpoint Slack includes with every command. Consumers finishing a long-running
+// command can use it to reply up to 30 minutes after the command was issued.
+func PostResponseURL(responseURL string, msg SlackMessage) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	resp, err := responseURLClient.Post(responseURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Drain the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("response_url returned %s", resp.Status)
+	}
+	return nil
+}
+
+// isSlackResponseURL reports whether a response_url points at Slack. The value
+// comes from the request body, so anything else is refused rather than letting
+// the relay be used to send requests to arbitrary hosts.
+func isSlackResponseURL(raw string) bool {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Scheme != "https" {
+		return false
+	}
+	host := parsed.Hostname()
+	return host == "slack.com" || strings.HasSuffix(host, ".slack.com")
+}
+
+// postWorkingMessage posts responseURLMessage to the command's response_url in
+// the background, so the acknowledgement isn't delayed
+func postWorkingMessage(requestID string, command SlackCommand) {
+	if !isSlackResponseURL(command.ResponseURL) {
+		logRequest(WARN, requestID, "Not posting to response_url for command %s: not a Slack https URL", command.Command)
+		return
+	}
+	responseURLPosts.Add(1)
+	go func() {
+		defer responseURLPosts.Done()
+		msg := SlackMessage{ResponseType: responseTypeEphemeral, Text: responseURLMessage}
+		if err := PostResponseURL(command.ResponseURL, msg); err != nil {
+			logRequest(WARN, requestID, "Error posting to response_url for command %s: %v", command.Command, err)
+			return
+		}
+		logRequest(DEBUG, requestID, "Posted working message to response_url for command %s", command.Command)
+	}()
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// redirectTransport sends every request to target, whatever its URL says
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (rt redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = rt.target.Scheme, rt.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestPostResponseURL(t *testing.T) {
+	var received SlackMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("expected JSON content type, got %q", ct)
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	if err := PostResponseURL(server.URL, SlackMessage{ResponseType: responseTypeInChannel, Text: "Deployed"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if received.ResponseType != responseTypeInChannel || received.Text != "Deployed" {
+		t.Errorf("unexpected message posted: %+v", received)
+	}
+}
+
+func TestPostResponseURL_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "expired_url", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	if err := PostResponseURL(server.URL, SlackMessage{Text: "late"}); err == nil {
+		t.Error("expected an error for a non-2xx response")
+	}
+}
+
+func TestIsSlackResponseURL(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"https://hooks.slack.com/commands/T1/123/abc", true},
+		{"https://slack.com/x", true},
+		{"http://hooks.slack.com/commands/T1/123/abc", false},
+		{"https://hooks.slack.com.evil.example/x", false},
+		{"https://evilslack.com/x", false},
+		{"https://169.254.169.254/latest/meta-data", false},
+		{"::not a url", false},
+	}
+	for _, tt := range tests {
+		if got := isSlackResponseURL(tt.input); got != tt.expected {
+			t.Errorf("isSlackResponseURL(%q) = %v, want %v", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestSlackCommandHandler_PostsWorkingMessage(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	setSigningSecrets(nil)
+	posted := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg SlackMessage
+		json.NewDecoder(r.Body).Decode(&msg)
+		posted <- r.URL.Path + " " + msg.Text
+	}))
+	defer server.Close()
+	target, _ := url.Parse(server.URL)
+
+	origUse, origMessage, origClient := useResponseURL, responseURLMessage, responseURLClient
+	t.Cleanup(func() { useResponseURL, responseURLMessage, responseURLClient = origUse, origMessage, origClient })
+	useResponseURL = true
+	responseURLMessage = "On it"
+	responseURLClient = &http.Client{Transport: redirectTransport{target: target}}
+
+	for _, responseURL := range []string{"https://hooks.slack.com/commands/T1/123/abc", "https://attacker.example/collect"} {
+		body := url.Values{"command": {"/deploy"}, "team_id": {"T1"}, "response_url": {responseURL}}.Encode()
+		w := httptest.NewRecorder()
+		slackCommandHandler(w, httptest.NewRequest(http.MethodPost, "/command", strings.NewReader(body)))
+		if w.Code != http.StatusOK {
+			t.Errorf("expected 200, got %d", w.Code)
+		}
+	}
+	responseURLPosts.Wait()
+	close(posted)
+
+	var got []string
+	for p := range posted {
+		got = append(got, p)
+	}
+	if len(got) != 1 || got[0] != "/commands/T1/123/abc On it" {
+		t.Errorf("expected a single post to the Slack response_url, got %q", got)
+	}
+}
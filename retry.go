@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultRetryQueueSize is the number of failed publishes buffered when RETRY_QUEUE_SIZE is unset
+	defaultRetryQueueSize = 1000
+
+	retryInitialBackoff = 1 * time.Second
+	// defaultRetryMaxBackoff caps the backoff interval when RETRY_MAX_BACKOFF is unset
+	defaultRetryMaxBackoff = 60 * time.Second
+)
+
+// retryItem is a command whose publish failed and is waiting to be retried
+type retryItem struct {
+	target  Publisher
+	backend string
+	key     string
+	command SlackCommand
+	payload []byte
+	seq     uint64
+
+	// requestID is the correlation ID of the original request, for publishers that send it
+	requestID string
+
+	// channel is the request's X-Relay-Channel override, if any
+	channel string
+}
+
+// retryQueue is a bounded in-memory queue of failed publishes. A background
+// goroutine retries the oldest item with jittered exponential backoff until it
+// succeeds. When the queue is full the oldest item is dropped to make room.
+type retryQueue struct {
+	mu      sync.Mutex
+	items   []retryItem
+	nextSeq uint64
+	maxSize int
+	notify  chan struct{}
+	publish func(ctx context.Context, item retryItem) error
+
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+var publishRetryQueue *retryQueue
+
+// retryMaxBackoff is the RETRY_MAX_BACKOFF cap on the retry backoff interval
+var retryMaxBackoff = defaultRetryMaxBackoff
+
+func newRetryQueue(maxSize int, publish func(ctx context.Context, item retryItem) error) *retryQueue {
+	return &retryQueue{
+		maxSize:        maxSize,
+		notify:         make(chan struct{}, 1),
+		publish:        publish,
+		initialBackoff: retryInitialBackoff,
+		maxBackoff:     retryMaxBackoff,
+	}
+}
+
+// backoffInterval returns the exponential backoff interval after the given
+// number of consecutive failures: initial doubled per failure, capped at max
+func backoffInterval(failures int, initial, max time.Duration) time.Duration {
+	interval := initial
+	for range failures - 1 {
+		if interval >= max/2 {
+			return max
+		}
+		interval *= 2
+	}
+	return min(interval, max)
+}
+
+// jitteredBackoff returns a random wait between 0 and the backoff interval
+// ("full jitter"), so relay replicas retrying a recovering backend spread out
+// instead of retrying in lockstep
+func jitteredBackoff(failures int, initial, max time.Duration) time.Duration {
+	interval := backoffInterval(failures, initial, max)
+	if interval <= 0 {
+		return 0
+	}
+	return rand.N(interval + 1)
+}
+
+// enqueue adds an item to the queue, dropping the oldest item if the queue is full
+func (q *retryQueue) enqueue(item retryItem) {
+	q.mu.Lock()
+	var dropped *retryItem
+	if len(q.items) >= q.maxSize {
+		oldest := q.items[0]
+		dropped = &oldest
+		q.items = q.items[1:]
+		logWarn("Retry queue full (%d items), dropping oldest command: %s", q.maxSize, dropped.command.Command)
+	}
+	q.nextSeq++
+	item.seq = q.nextSeq
+	q.items = append(q.items, item)
+	q.mu.Unlock()
+
+	if dropped != nil {
+		deadLetterItem(*dropped, "retry queue full")
+	}
+
+	// Wake the retry loop without blocking if it is already awake
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// len returns the number of items waiting to be retried
+func (q *retryQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+// peek returns the oldest item without removing it
+func (q *retryQueue) peek() (retryItem, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.items) == 0 {
+		return retryItem{}, false
+	}
+	return q.items[0], true
+}
+
+// remove drops the oldest item if it is still the given one. The item may have
+// already been evicted by enqueue while a retry was in flight.
+func (q *retryQueue) remove(item retryItem) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.items) > 0 && q.items[0].seq == item.seq {
+		q.items = q.items[1:]
+	}
+}
+
+// start runs the retry loop in the background until stop is called
+func (q *retryQueue) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	q.cancel = cancel
+	q.done = make(chan struct{})
+	go func() {
+		defer close(q.done)
+		q.run(ctx)
+	}()
+}
+
+// stop halts the background retry loop and waits for it to exit
+func (q *retryQueue) stop() {
+	q.cancel()
+	<-q.done
+}
+
+// takeAll removes and returns every queued item
+func (q *retryQueue) takeAll() []retryItem {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	items := q.items
+	q.items = nil
+	return items
+}
+
+// drain makes a final publish attempt for each queued item until one fails or
+// ctx expires. It returns the number of items left unpublished.
+func (q *retryQueue) drain(ctx context.Context) int {
+	for {
+		item, ok := q.peek()
+		if !ok {
+			return 0
+		}
+		if ctx.Err() != nil {
+			return q.len()
+		}
+		if err := q.publish(ctx, item); err != nil {
+			logWarn("Failed to drain retry queue: %v", err)
+			return q.len()
+		}
+		q.remove(item)
+	}
+}
+
+// run retries queued items until ctx is cancelled
+func (q *retryQueue) run(ctx context.Context) {
+	failures := 0
+	for {
+		item, ok := q.peek()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return
+			case <-q.notify:
+				continue
+			}
+		}
+
+		err := q.publish(ctx, item)
+		if err == nil {
+			q.remove(item)
+			logInfo("Retried publish succeeded for command: %s (%d remaining)", item.command.Command, q.len())
+			failures = 0
+			continue
+		}
+
+		failures++
+		backoff := jitteredBackoff(failures, q.initialBackoff, q.maxBackoff)
+		logWarn("Retried publish failed for command %s, next attempt in %s: %v", item.command.Command, backoff.Round(time.Millisecond), err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryQueue_DropsOldestWhenFull(t *testing.T) {
+	q := newRetryQueue(2, nil)
+	q.enqueue(retryItem{command: SlackCommand{Command: "/one"}})
+	q.enqueue(retryItem{command: SlackCommand{Command: "/two"}})
+	q.enqueue(retryItem{command: SlackCommand{Command: "/three"}})
+
+	if q.len() != 2 {
+		t.Fatalf("expected 2 queued items, got %d", q.len())
+	}
+	item, _ := q.peek()
+	if item.command.Command != "/two" {
+		t.Errorf("expected oldest item to be /two, got %s", item.command.Command)
+	}
+}
+
+func TestRetryQueue_RetriesUntilSuccess(t *testing.T) {
+	var attempts atomic.Int32
+	q := newRetryQueue(10, func(ctx context.Context, item retryItem) error {
+		if attempts.Add(1) < 3 {
+			return errors.New("redis unavailable")
+		}
+		return nil
+	})
+	q.initialBackoff = time.Millisecond
+	q.maxBackoff = 2 * time.Millisecond
+
+	q.start()
+	defer q.stop()
+
+	q.enqueue(retryItem{command: SlackCommand{Command: "/test"}, payload: []byte("{}")})
+
+	deadline := time.Now().Add(time.Second)
+	for q.len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if q.len() != 0 {
+		t.Fatalf("expected queue to drain, %d items remaining", q.len())
+	}
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("expected 3 publish attempts, got %d", got)
+	}
+}
+
+func TestRetryQueue_Drain(t *testing.T) {
+	var published []string
+	q := newRetryQueue(10, func(ctx context.Context, item retryItem) error {
+		published = append(published, item.command.Command)
+		return nil
+	})
+	q.enqueue(retryItem{command: SlackCommand{Command: "/one"}})
+	q.enqueue(retryItem{command: SlackCommand{Command: "/two"}})
+
+	if remaining := q.drain(context.Background()); remaining != 0 {
+		t.Errorf("expected 0 remaining, got %d", remaining)
+	}
+	if len(published) != 2 || published[0] != "/one" || published[1] != "/two" {
+		t.Errorf("expected commands drained in order, got %v", published)
+	}
+}
+
+func TestRetryQueue_DrainStopsOnFailure(t *testing.T) {
+	q := newRetryQueue(10, func(ctx context.Context, item retryItem) error {
+		return errors.New("redis unavailable")
+	})
+	q.enqueue(retryItem{command: SlackCommand{Command: "/one"}})
+	q.enqueue(retryItem{command: SlackCommand{Command: "/two"}})
+
+	if remaining := q.drain(context.Background()); remaining != 2 {
+		t.Errorf("expected 2 remaining, got %d", remaining)
+	}
+}
+
+func TestBackoffInterval(t *testing.T) {
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for i, expected := range want {
+		if got := backoffInterval(i+1, time.Second, 10*time.Second); got != expected {
+			t.Errorf("failure %d: expected %s, got %s", i+1, expected, got)
+		}
+	}
+	// Many failures must not overflow past the cap
+	if got := backoffInterval(1000, time.Second, time.Minute); got != time.Minute {
+		t.Errorf("expected the cap after many failures, got %s", got)
+	}
+}
+
+func TestJitteredBackoff_StaysWithinBounds(t *testing.T) {
+	const initial, maxBackoff = 100 * time.Millisecond, 2 * time.Second
+	for failures := 1; failures <= 20; failures++ {
+		interval := backoffInterval(failures, initial, maxBackoff)
+		var spread bool
+		for range 200 {
+			got := jitteredBackoff(failures, initial, maxBackoff)
+			if got < 0 || got > interval || got > maxBackoff {
+				t.Fatalf("failure %d: backoff %s outside [0, %s]", failures, got, interval)
+			}
+			if got != interval {
+				spread = true
+			}
+		}
+		if !spread {
+			t.Errorf("failure %d: expected jittered backoffs, always got %s", failures, interval)
+		}
+	}
+}
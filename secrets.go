@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// secretFile holds one or more Slack signing secrets, one per line, when SECRET_FILE is unset
+	secretFile = ".secret"
+
+	// secretsDir holds one file per Slack app, each containing that app's signing secret(s)
+	secretsDir = ".secrets"
+
+	// defaultSecretReloadInterval is how often secretFile is re-read when SECRET_RELOAD_INTERVAL is unset
+	defaultSecretReloadInterval = 30 * time.Second
+)
+
+// signingSecrets holds the currently valid signing secrets. It is swapped
+// atomically when the secret file changes so rotation needs no restart.
+var signingSecrets atomic.Pointer[[][]byte]
+
+// getSigningSecrets returns the currently valid signing secrets
+func getSigningSecrets() [][]byte {
+	if secrets := signingSecrets.Load(); secrets != nil {
+		return *secrets
+	}
+	return nil
+}
+
+// secretsMap maps a team_id or api_app_id to its signing secrets, from
+// SECRETS_MAP. A request is checked only against the secrets of the app or
+// team it names rather than every configured secret.
+var secretsMap map[string][][]byte
+
+// setSigningSecrets replaces the currently valid signing secrets
+func setSigningSecrets(secrets [][]byte) {
+	signingSecrets.Store(&secrets)
+}
+
+// parseSecrets splits secret file contents into one secret per non-empty line.
+// Listing both the old and new secret allows either during a rotation window.
+func parseSecrets(data string) [][]byte {
+	var secrets [][]byte
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			secrets = append(secrets, []byte(line))
+		}
+	}
+	return secrets
+}
+
+// parseSecretsMap parses SECRETS_MAP, a JSON object mapping team or app IDs to
+// a secret, or comma-separated secrets during a rotation, such as
+// {"A0123":"secret-a","T0456":"old,new"}
+func parseSecretsMap(value string) (map[string][][]byte, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	var raw map[string]string
+	if err := json.Unmarshal([]byte(value), &raw); err != nil {
+		return nil, fmt.Errorf("parsing JSON: %w", err)
+	}
+	mapped := make(map[string][][]byte, len(raw))
+	for id, value := range raw {
+		if id == "" {
+			return nil, errors.New("empty team or app ID")
+		}
+		for _, secret := range splitList(value) {
+			mapped[id] = append(mapped[id], []byte(secret))
+		}
+		if len(mapped[id]) == 0 {
+			return nil, fmt.Errorf("no secret for %s", id)
+		}
+	}
+	return mapped, nil
+}
+
+// requestIdentity returns the team and app IDs named by an unverified request
+// body: its form fields, the JSON payload of an interactive component, or a
+// JSON command body
+func requestIdentity(body []byte) (teamID, appID string) {
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")) {
+		var fields struct {
+			TeamID   string `json:"team_id"`
+			APIAppID string `json:"api_app_id"`
+		}
+		json.Unmarshal(body, &fields)
+		return fields.TeamID, fields.APIAppID
+	}
+
+	// ParseQuery keeps the pairs it could parse when it returns an error
+	values, _ := url.ParseQuery(string(body))
+	if raw := values.Get("payload"); raw != "" {
+		var payload struct {
+			Team struct {
+				ID string `json:"id"`
+			} `json:"team"`
+			APIAppID string `json:"api_app_id"`
+		}
+		if json.Unmarshal([]byte(raw), &payload) == nil {
+			return payload.Team.ID, payload.APIAppID
+		}
+	}
+	return values.Get("team_id"), values.Get("api_app_id")
+}
+
+// signingSecretsFor returns the secrets a request body must be signed with.
+// With SECRETS_MAP, those of the app the request names are used, then those of
+// its team; otherwise the global secrets are. ok is false when SECRETS_MAP is
+// set but no secret applies, so the request must be rejected rather than
+// accepted unverified.
+func signingSecretsFor(body []byte) (secrets [][]byte, ok bool) {
+	global := getSigningSecrets()
+	if len(secretsMap) == 0 {
+		return global, true
+	}
+	teamID, appID := requestIdentity(body)
+	for _, id := range []string{appID, teamID} {
+		if mapped, found := secretsMap[id]; found && id != "" {
+			return mapped, true
+		}
+	}
+	return global, len(global) > 0
+}
+
+// signatureVerificationEnabled reports whether any signing secret is configured
+func signatureVerificationEnabled() bool {
+	return len(getSigningSecrets()) > 0 || len(secretsMap) > 0
+}
+
+// readSecrets reads the secrets stored at path. If path is a directory, the
+// secrets from every regular file in it are combined.
+func readSecrets(path string) ([][]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return parseSecrets(string(data)), nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var secrets [][]byte
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(path, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, parseSecrets(string(data))...)
+	}
+	return secrets, nil
+}
+
+// loadSigningSecrets loads the signing secrets at startup. SLACK_SIGNING_SECRET
+// (comma-separated for several apps) takes precedence, then the .secrets
+// directory, then the secret file at secretPath. File-based secrets are watched
+// for changes.
+func loadSigningSecrets(secretPath string) {
+	if envSecrets := splitList(os.Getenv("SLACK_SIGNING_SECRET")); len(envSecrets) > 0 {
+		secrets := make([][]byte, len(envSecrets))
+		for i, secret := range envSecrets {
+			secrets[i] = []byte(secret)
+		}
+		setSigningSecrets(secrets)
+		logInfo("%d Slack signing secret(s) loaded from SLACK_SIGNING_SECRET. Signature verification enabled.", len(secrets))
+		return
+	}
+
+	for _, path := range []string{secretsDir, secretPath} {
+		secrets, err := readSecrets(path)
+		if err != nil || len(secrets) == 0 {
+			continue
+		}
+		setSigningSecrets(secrets)
+		logInfo("%d Slack signing secret(s) loaded from %s. Signature verification enabled.", len(secrets), path)
+
+		// Pick up rotated secrets without a restart
+		reloadInterval := getEnvDuration("SECRET_RELOAD_INTERVAL", defaultSecretReloadInterval)
+		go watchSecrets(context.Background(), path, reloadInterval)
+		logInfo("Watching %s for changes every %s", path, reloadInterval)
+		return
+	}
+
+	if len(secretsMap) > 0 {
+		logInfo("No global Slack signing secret; requests must match a SECRETS_MAP entry.")
+		return
+	}
+	logWarn("No Slack signing secret found. Slack signature verification will be skipped.")
+	logWarn("To enable verification, set SLACK_SIGNING_SECRET or create a .secret file with your Slack signing secret.")
+}
+
+// secretsEqual reports whether two secret lists are identical
+func secretsEqual(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// watchSecrets periodically re-reads the secret file or directory at path and swaps
+// in its secrets when they change. A missing or empty source keeps the current
+// secrets, so a bad rotation never silently disables verification. It runs until
+// ctx is cancelled.
+func watchSecrets(ctx context.Context, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		secrets, err := readSecrets(path)
+		if err != nil {
+			logWarn("Could not re-read %s, keeping current signing secrets: %v", path, err)
+			continue
+		}
+		if len(secrets) == 0 {
+			logWarn("%s is empty, keeping current signing secrets", path)
+			continue
+		}
+		if secretsEqual(secrets, getSigningSecrets()) {
+			continue
+		}
+		setSigningSecrets(secrets)
+		logInfo("Reloaded %d Slack signing secret(s) from %s", len(secrets), path)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSecrets(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected [][]byte
+	}{
+		{"", nil},
+		{"secret\n", [][]byte{[]byte("secret")}},
+		{"old\r\nnew\n\n", [][]byte{[]byte("old"), []byte("new")}},
+		{"  padded  ", [][]byte{[]byte("padded")}},
+	}
+	for _, tt := range tests {
+		if got := parseSecrets(tt.input); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("parseSecrets(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestReadSecrets_Directory(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "app-a"), []byte("secret-a\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "app-b"), []byte("secret-b1\nsecret-b2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "nested"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	secrets, err := readSecrets(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := [][]byte{[]byte("secret-a"), []byte("secret-b1"), []byte("secret-b2")}
+	if !secretsEqual(secrets, expected) {
+		t.Errorf("readSecrets(dir) = %q, want %q", secrets, expected)
+	}
+}
+
+func TestLoadSigningSecrets_EnvList(t *testing.T) {
+	orig := getSigningSecrets()
+	t.Cleanup(func() { setSigningSecrets(orig) })
+	t.Setenv("SLACK_SIGNING_SECRET", "app-one-secret, app-two-secret")
+
+	loadSigningSecrets(secretFile)
+
+	expected := [][]byte{[]byte("app-one-secret"), []byte("app-two-secret")}
+	if !secretsEqual(getSigningSecrets(), expected) {
+		t.Errorf("expected secrets from env list, got %q", getSigningSecrets())
+	}
+}
+
+func TestLoadSigningSecrets_EnvOverridesFile(t *testing.T) {
+	orig := getSigningSecrets()
+	t.Cleanup(func() { setSigningSecrets(orig) })
+	path := filepath.Join(t.TempDir(), ".secret")
+	if err := os.WriteFile(path, []byte("file-secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("SLACK_SIGNING_SECRET", "env-secret")
+	loadSigningSecrets(path)
+	if !secretsEqual(getSigningSecrets(), [][]byte{[]byte("env-secret")}) {
+		t.Errorf("expected SLACK_SIGNING_SECRET to take precedence over the file, got %q", getSigningSecrets())
+	}
+
+	// Without the variable the file is used
+	t.Setenv("SLACK_SIGNING_SECRET", "")
+	t.Setenv("SECRET_RELOAD_INTERVAL", "1h")
+	loadSigningSecrets(path)
+	if !secretsEqual(getSigningSecrets(), [][]byte{[]byte("file-secret")}) {
+		t.Errorf("expected the file secret without SLACK_SIGNING_SECRET, got %q", getSigningSecrets())
+	}
+}
+
+func TestWatchSecrets_ReloadsRotatedSecrets(t *testing.T) {
+	orig := getSigningSecrets()
+	t.Cleanup(func() { setSigningSecrets(orig) })
+
+	path := filepath.Join(t.TempDir(), ".secret")
+	if err := os.WriteFile(path, []byte("old\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	setSigningSecrets([][]byte{[]byte("old")})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go watchSecrets(ctx, path, 5*time.Millisecond)
+
+	if err := os.WriteFile(path, []byte("old\nnew\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := [][]byte{[]byte("old"), []byte("new")}
+	deadline := time.Now().Add(time.Second)
+	for !secretsEqual(getSigningSecrets(), expected) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !secretsEqual(getSigningSecrets(), expected) {
+		t.Fatalf("expected rotated secrets, got %q", getSigningSecrets())
+	}
+
+	// An empty file must not disable verification
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if !secretsEqual(getSigningSecrets(), expected) {
+		t.Errorf("expected secrets to be kept when file is emptied, got %q", getSigningSecrets())
+	}
+}
+
+func TestParseSecretsMap(t *testing.T) {
+	mapped, err := parseSecretsMap(`{"A1":"app-secret","T1":"old, new"}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string][][]byte{
+		"A1": {[]byte("app-secret")},
+		"T1": {[]byte("old"), []byte("new")},
+	}
+	if !reflect.DeepEqual(mapped, expected) {
+		t.Errorf("parseSecretsMap = %q, want %q", mapped, expected)
+	}
+
+	if mapped, err := parseSecretsMap(""); mapped != nil || err != nil {
+		t.Errorf("expected nil for an empty value, got %v, %v", mapped, err)
+	}
+	for _, invalid := range []string{`not json`, `{"T1":""}`, `{"":"secret"}`} {
+		if _, err := parseSecretsMap(invalid); err == nil {
+			t.Errorf("expected an error for %s", invalid)
+		}
+	}
+}
+
+func TestRequestIdentity(t *testing.T) {
+	tests := []struct {
+		body      string
+		team, app string
+	}{
+		{"command=%2Fdeploy&team_id=T1&api_app_id=A1", "T1", "A1"},
+		{`payload=%7B%22team%22%3A%7B%22id%22%3A%22T2%22%7D%2C%22api_app_id%22%3A%22A2%22%7D`, "T2", "A2"},
+		{`{"command":"/deploy","team_id":"T3","api_app_id":"A3"}`, "T3", "A3"},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		if team, app := requestIdentity([]byte(tt.body)); team != tt.team || app != tt.app {
+			t.Errorf("requestIdentity(%q) = %q, %q, want %q, %q", tt.body, team, app, tt.team, tt.app)
+		}
+	}
+}
+
+func TestSigningSecretsFor(t *testing.T) {
+	origSecrets, origMap := getSigningSecrets(), secretsMap
+	t.Cleanup(func() {
+		setSigningSecrets(origSecrets)
+		secretsMap = origMap
+	})
+	global := [][]byte{[]byte("global")}
+	setSigningSecrets(global)
+	secretsMap = map[string][][]byte{
+		"A1": {[]byte("app")},
+		"T1": {[]byte("team")},
+	}
+
+	tests := []struct {
+		body     string
+		expected [][]byte
+	}{
+		// The app takes precedence over the team
+		{"team_id=T1&api_app_id=A1", secretsMap["A1"]},
+		{"team_id=T1&api_app_id=A9", secretsMap["T1"]},
+		{"team_id=T9", global},
+	}
+	for _, tt := range tests {
+		secrets, ok := signingSecretsFor([]byte(tt.body))
+		if !ok || !reflect.DeepEqual(secrets, tt.expected) {
+			t.Errorf("signingSecretsFor(%q) = %q, %t, want %q", tt.body, secrets, ok, tt.expected)
+		}
+	}
+
+	// Without global secrets an unmapped team must be rejected, not skipped
+	setSigningSecrets(nil)
+	if _, ok := signingSecretsFor([]byte("team_id=T9")); ok {
+		t.Error("expected an unmapped team to be rejected without global secrets")
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+)
+
+const (
+	// snsPublishTimeout bounds each SNS publish
+	snsPublishTimeout = 5 * time.Second
+
+	// snsDefaultMessageGroup is the FIFO message group for commands without a
+	// partition key, since SNS rejects FIFO publishes that lack one
+	snsDefaultMessageGroup = "slack-commands"
+)
+
+// snsAPI is the subset of the SNS client used by snsPublisher
+type snsAPI interface {
+	Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
+}
+
+// snsPublisher publishes each command to an SNS topic. The command name is
+// attached as a message attribute so subscribers can use filter policies.
+type snsPublisher struct {
+	client   snsAPI
+	topicARN string
+}
+
+// newSNSPublisher creates a publisher using the standard AWS credential chain
+// and region configuration (AWS_REGION, AWS_PROFILE, instance roles, ...)
+func newSNSPublisher(ctx context.Context, topicARN string) (*snsPublisher, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &snsPublisher{client: sns.NewFromConfig(cfg), topicARN: topicARN}, nil
+}
+
+func (p *snsPublisher) Publish(ctx context.Context, key string, payload []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, snsPublishTimeout)
+	defer cancel()
+
+	input := &sns.PublishInput{
+		TopicArn: aws.String(p.topicARN),
+		Message:  aws.String(string(payload)),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"command": {
+				DataType:    aws.String("String"),
+				StringValue: aws.String(publishMetaFrom(ctx).command.Command),
+			},
+		},
+	}
+	// FIFO topics require a message group; use the partition key to keep ordering per group
+	if strings.HasSuffix(p.topicARN, ".fifo") {
+		if key == "" {
+			key = snsDefaultMessageGroup
+		}
+		input.MessageGroupId = aws.String(key)
+	}
+
+	_, err := p.client.Publish(ctx, input)
+	return err
+}
+
+func (p *snsPublisher) Close() error {
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+type fakeSNSClient struct {
+	input *sns.PublishInput
+	err   error
+}
+
+func (f *fakeSNSClient) Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error) {
+	f.input = params
+	return &sns.PublishOutput{}, f.err
+}
+
+func TestSNSPublisher_Publish(t *testing.T) {
+	client := &fakeSNSClient{}
+	publisher := &snsPublisher{client: client, topicARN: "arn:aws:sns:us-east-1:123456789012:slack-commands"}
+
+	ctx := withPublishMeta(context.Background(), SlackCommand{Command: "/deploy"}, "")
+	if err := publisher.Publish(ctx, "T1", []byte(`{"id":"1"}`)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if *client.input.TopicArn != publisher.topicARN {
+		t.Errorf("expected topic ARN %s, got %s", publisher.topicARN, *client.input.TopicArn)
+	}
+	if *client.input.Message != `{"id":"1"}` {
+		t.Errorf("expected payload as message body, got %s", *client.input.Message)
+	}
+	if attr := client.input.MessageAttributes["command"]; attr.StringValue == nil || *attr.StringValue != "/deploy" {
+		t.Errorf("expected command message attribute, got %+v", attr)
+	}
+	if client.input.MessageGroupId != nil {
+		t.Errorf("expected no message group for a standard topic, got %s", *client.input.MessageGroupId)
+	}
+}
+
+func TestSNSPublisher_FIFOTopicUsesMessageGroup(t *testing.T) {
+	client := &fakeSNSClient{}
+	publisher := &snsPublisher{client: client, topicARN: "arn:aws:sns:us-east-1:123456789012:slack-commands.fifo"}
+
+	if err := publisher.Publish(context.Background(), "T1", []byte("{}")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if client.input.MessageGroupId == nil || *client.input.MessageGroupId != "T1" {
+		t.Errorf("expected message group T1, got %v", client.input.MessageGroupId)
+	}
+
+	// A command without the partition key field still needs a group
+	if err := publisher.Publish(context.Background(), "", []byte("{}")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if client.input.MessageGroupId == nil || *client.input.MessageGroupId != snsDefaultMessageGroup {
+		t.Errorf("expected message group %s for an empty key, got %v", snsDefaultMessageGroup, client.input.MessageGroupId)
+	}
+}
+
+func TestSNSPublisher_Error(t *testing.T) {
+	publisher := &snsPublisher{client: &fakeSNSClient{err: errors.New("throttled")}, topicARN: "arn"}
+	if err := publisher.Publish(context.Background(), "", []byte("{}")); err == nil {
+		t.Error("expected publish error to be returned")
+	}
+}
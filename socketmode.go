@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/socketmode"
+)
+
+// Socket Mode connection settings
+const (
+	// socketModeReconnectDelay is the pause before reconnecting after the
+	// Socket Mode client gives up on a connection
+	socketModeReconnectDelay = 5 * time.Second
+	// socketModeAckTimeout bounds queueing an acknowledgement for the socket
+	socketModeAckTimeout = 3 * time.Second
+	// socketModeRemoteAddr stands in for the client address of Socket Mode commands
+	socketModeRemoteAddr = "slack-socket-mode"
+)
+
+// socketMode receives commands over an outbound Socket Mode connection to
+// Slack, from SOCKET_MODE. It needs an app-level token in SLACK_APP_TOKEN.
+var socketMode bool
+
+// stopSocketMode disconnects from Slack after in-flight commands are acknowledged
+var stopSocketMode func()
+
+// socketModeContextKey marks requests relayed from Socket Mode. Slack
+// authenticates the connection with the app token, so they carry no signature.
+type socketModeContextKey struct{}
+
+// fromSocketMode reports whether a request was relayed from Socket Mode
+func fromSocketMode(ctx context.Context) bool {
+	relayed, _ := ctx.Value(socketModeContextKey{}).(bool)
+	return relayed
+}
+
+// validateAppToken checks that a token is an app-level token; bot and user
+// tokens can't open Socket Mode connections
+func validateAppToken(token string) error {
+	if !strings.HasPrefix(token, "xapp-") {
+		return errors.New("must be an app-level token starting with xapp-")
+	}
+	return nil
+}
+
+// socketModeAcker acknowledges Socket Mode envelopes; *socketmode.Client implements it
+type socketModeAcker interface {
+	AckCtx(ctx context.Context, envelopeID string, payload any) error
+}
+
+// startSocketMode connects to Slack with appToken and passes each slash
+// command to handler as if it had been POSTed to the command path. The
+// returned function stops it.
+func startSocketMode(appToken string, handler http.Handler) func() {
+	api := slack.New("", slack.OptionAppLevelToken(appToken))
+	client := socketmode.New(api)
+
+	// The connection outlives the event loop so in-flight commands can still be acknowledged
+	runCtx, stopRun := context.WithCancel(context.Background())
+	loopCtx, stopLoop := context.WithCancel(context.Background())
+	var inFlight sync.WaitGroup
+
+	go func() {
+		for {
+			err := client.RunContext(runCtx)
+			if runCtx.Err() != nil {
+				return
+			}
+			logError("Socket Mode connection failed, reconnecting in %s: %v", socketModeReconnectDelay, err)
+			select {
+			case <-time.After(socketModeReconnectDelay):
+			case <-runCtx.Done():
+				return
+			}
+		}
+	}()
+
+	loopDone := make(chan struct{})
+	go func() {
+		defer close(loopDone)
+		for {
+			select {
+			case <-loopCtx.Done():
+				return
+			case event := <-client.Events:
+				inFlight.Go(func() {
+					handleSocketModeEvent(client, handler, event)
+				})
+			}
+		}
+	}()
+
+	return func() {
+		stopLoop()
+		<-loopDone
+		inFlight.Wait()
+		stopRun()
+	}
+}
+
+// handleSocketModeEvent relays slash commands and acknowledges every envelope
+// so Slack doesn't redeliver it. Other events only log the connection state.
+func handleSocketModeEvent(acker socketModeAcker, handler http.Handler, event socketmode.Event) {
+	switch event.Type {
+	case socketmode.EventTypeConnecting:
+		logInfo("Connecting to Slack Socket Mode")
+	case socketmode.EventTypeConnected:
+		logInfo("Connected to Slack Socket Mode")
+	case socketmode.EventTypeConnectionError:
+		logWarn("Slack Socket Mode connection error: %v", event.Data)
+	case socketmode.EventTypeInvalidAuth:
+		logError("Slack rejected SLACK_APP_TOKEN for Socket Mode")
+	case socketmode.EventTypeSlashCommand:
+		command, ok := event.Data.(slack.SlashCommand)
+		if !ok {
+			logWarn("Ignoring Socket Mode slash command with unexpected data %T", event.Data)
+			ackSocketMode(acker, event.Request, nil)
+			return
+		}
+		ackSocketMode(acker, event.Request, relaySocketModeCommand(handler, command, event.Request))
+	case socketmode.EventTypeEventsAPI, socketmode.EventTypeInteractive:
+		logDebug("Ignoring Socket Mode %s envelope", event.Type)
+		ackSocketMode(acker, event.Request, nil)
+	}
+}
+
+// ackSocketMode acknowledges an envelope, with the response Slack shows the user if any
+func ackSocketMode(acker socketModeAcker, request *socketmode.Request, payload any) {
+	if request == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), socketModeAckTimeout)
+	defer cancel()
+	if err := acker.AckCtx(ctx, request.EnvelopeID, payload); err != nil {
+		logError("Error acknowledging Socket Mode envelope %s: %v", request.EnvelopeID, err)
+	}
+}
+
+// relaySocketModeCommand runs a Socket Mode slash command through handler as
+// the form POST Slack would otherwise have sent, so it is filtered, rate
+// limited and published exactly like an HTTP command. It returns the
+// acknowledgement payload, or nil for an empty acknowledgement.
+func relaySocketModeCommand(handler http.Handler, command slack.SlashCommand, request *socketmode.Request) any {
+	form := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			form.Set(key, value)
+		}
+	}
+	set("token", command.Token)
+	set("team_id", command.TeamID)
+	set("team_domain", command.TeamDomain)
+	set("enterprise_id", command.EnterpriseID)
+	set("enterprise_name", command.EnterpriseName)
+	set("channel_id", command.ChannelID)
+	set("channel_name", command.ChannelName)
+	set("user_id", command.UserID)
+	set("user_name", command.UserName)
+	set("command", command.Command)
+	set("text", command.Text)
+	set("response_url", command.ResponseURL)
+	set("trigger_id", command.TriggerID)
+	set("api_app_id", command.APIAppID)
+
+	ctx := context.WithValue(context.Background(), socketModeContextKey{}, true)
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, "/", strings.NewReader(form.Encode()))
+	if err != nil {
+		logError("Error relaying Socket Mode command %s: %v", command.Command, err)
+		return nil
+	}
+	r.RemoteAddr = socketModeRemoteAddr
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if request != nil && request.RetryAttempt > 0 {
+		r.Header.Set(retryNumHeader, strconv.Itoa(request.RetryAttempt))
+		r.Header.Set(retryReasonHeader, request.RetryReason)
+	}
+
+	w := &socketModeResponse{header: make(http.Header)}
+	handler.ServeHTTP(w, r)
+	return w.payload()
+}
+
+// socketModeResponse captures the handler's response to turn it into an acknowledgement
+type socketModeResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *socketModeResponse) Header() http.Header { return w.header }
+
+func (w *socketModeResponse) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *socketModeResponse) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
+
+// payload converts the response into an acknowledgement payload. A JSON
+// response is passed through; a plain-text error is shown to the user as an
+// ephemeral message, since Socket Mode has no HTTP status to report it with.
+func (w *socketModeResponse) payload() any {
+	body := bytes.TrimSpace(w.body.Bytes())
+	if len(body) == 0 {
+		return nil
+	}
+	if isJSONContentType(w.header.Get("Content-Type")) && json.Valid(body) {
+		return json.RawMessage(body)
+	}
+	return SlackResponse{ResponseType: responseTypeEphemeral, Text: string(body)}
+}
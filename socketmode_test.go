@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/socketmode"
+)
+
+// fakeAcker records Socket Mode acknowledgements
+type fakeAcker struct {
+	envelopes []string
+	payloads  []any
+}
+
+func (a *fakeAcker) AckCtx(_ context.Context, envelopeID string, payload any) error {
+	a.envelopes = append(a.envelopes, envelopeID)
+	a.payloads = append(a.payloads, payload)
+	return nil
+}
+
+var socketModeCommand = slack.SlashCommand{
+	Token:    "legacy-token",
+	TeamID:   "T1",
+	UserName: "alice",
+	Command:  "/deploy",
+	Text:     "api production",
+}
+
+func TestRelaySocketModeCommand_PublishesWithoutSignature(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	// A configured signing secret must not reject commands Slack sent over the socket
+	setSigningSecrets([][]byte{[]byte("test-secret")})
+	publishQueue = nil
+	fake := &fakePublisher{}
+	activePublisher = fake
+
+	payload := relaySocketModeCommand(http.HandlerFunc(slackCommandHandler), socketModeCommand,
+		&socketmode.Request{EnvelopeID: "env-1", RetryAttempt: 1, RetryReason: "timeout"})
+
+	raw, ok := payload.(json.RawMessage)
+	if !ok {
+		t.Fatalf("expected the JSON acknowledgement, got %#v", payload)
+	}
+	var response SlackResponse
+	if err := json.Unmarshal(raw, &response); err != nil || response.Text == "" {
+		t.Errorf("unexpected acknowledgement %s: %v", raw, err)
+	}
+	if len(fake.payloads) != 1 {
+		t.Fatalf("expected 1 publish, got %d", len(fake.payloads))
+	}
+	var envelope struct {
+		Command     SlackCommand `json:"command"`
+		Args        []string     `json:"args"`
+		RetryNum    int          `json:"retry_num"`
+		RetryReason string       `json:"retry_reason"`
+	}
+	if err := json.Unmarshal(fake.payloads[0], &envelope); err != nil {
+		t.Fatal(err)
+	}
+	if envelope.Command.Command != "/deploy" || envelope.Command.UserName != "alice" || len(envelope.Args) != 2 {
+		t.Errorf("unexpected published command %+v", envelope)
+	}
+	if envelope.RetryNum != 1 || envelope.RetryReason != "timeout" {
+		t.Errorf("expected the retry attempt to be recorded, got %d %q", envelope.RetryNum, envelope.RetryReason)
+	}
+}
+
+func TestRelaySocketModeCommand_ErrorIsEphemeral(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	setSigningSecrets(nil)
+	teamAllowlist = toSet([]string{"T2"})
+
+	payload := relaySocketModeCommand(http.HandlerFunc(slackCommandHandler), socketModeCommand, nil)
+	response, ok := payload.(SlackResponse)
+	if !ok || response.ResponseType != responseTypeEphemeral || response.Text != "Team not allowed" {
+		t.Errorf("expected an ephemeral rejection, got %#v", payload)
+	}
+}
+
+func TestHandleSocketModeEvent_AcksEnvelopes(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	setSigningSecrets(nil)
+	publishQueue = nil
+	fake := &fakePublisher{}
+	activePublisher = fake
+	acker := &fakeAcker{}
+	handler := http.HandlerFunc(slackCommandHandler)
+
+	handleSocketModeEvent(acker, handler, socketmode.Event{Type: socketmode.EventTypeConnected})
+	handleSocketModeEvent(acker, handler, socketmode.Event{Type: socketmode.EventTypeSlashCommand, Data: socketModeCommand, Request: &socketmode.Request{EnvelopeID: "env-1"}})
+	handleSocketModeEvent(acker, handler, socketmode.Event{Type: socketmode.EventTypeInteractive, Request: &socketmode.Request{EnvelopeID: "env-2"}})
+
+	if len(acker.envelopes) != 2 || acker.envelopes[0] != "env-1" || acker.envelopes[1] != "env-2" {
+		t.Errorf("expected both envelopes to be acknowledged, got %v", acker.envelopes)
+	}
+	if acker.payloads[0] == nil || acker.payloads[1] != nil {
+		t.Errorf("expected only the slash command to get a response, got %#v", acker.payloads)
+	}
+	if len(fake.payloads) != 1 {
+		t.Errorf("expected only the slash command to be published, got %d", len(fake.payloads))
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Process-lifetime counters for /stats, a quick human-readable complement to /metrics
+var (
+	statsReceived        atomic.Int64
+	statsPublished       atomic.Int64
+	statsPublishFailures atomic.Int64
+)
+
+// startTime is when the process started, for uptime reporting
+var startTime = time.Now()
+
+// Stats is the /stats response body. It reports the running configuration
+// but deliberately no secrets, tokens or connection strings.
+type Stats struct {
+	Received        int64  `json:"received"`
+	Published       int64  `json:"published"`
+	PublishFailures int64  `json:"publish_failures"`
+	UptimeSeconds   int64  `json:"uptime_seconds"`
+	Backend         string `json:"backend"`
+	Channel         string `json:"channel,omitempty"`
+}
+
+// currentStats snapshots the counters and the relevant configuration
+func currentStats() Stats {
+	stats := Stats{
+		Received:        statsReceived.Load(),
+		Published:       statsPublished.Load(),
+		PublishFailures: statsPublishFailures.Load(),
+		UptimeSeconds:   int64(time.Since(startTime).Seconds()),
+		Backend:         strings.Join(backends, ","),
+	}
+	if hasBackend(backendRedis) {
+		stats.Channel = redisChannel
+	}
+	return stats
+}
+
+// statsHandler serves the runtime counters as JSON
+func statsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(currentStats())
+}
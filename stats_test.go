@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStatsHandler_CountsCommands(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	setSigningSecrets(nil)
+	origChannel := redisChannel
+	t.Cleanup(func() { redisChannel = origChannel })
+	backends = []string{backendRedis}
+	redisChannel = "ops-commands"
+	received, published, failures := statsReceived.Load(), statsPublished.Load(), statsPublishFailures.Load()
+
+	publisher := &fakePublisher{}
+	activePublisher = publisher
+	for i := 0; i < 2; i++ {
+		slackCommandHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/command", strings.NewReader("command=%2Fdeploy&team_id=T1")))
+	}
+	publisher.err = errors.New("broker down")
+	slackCommandHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/command", strings.NewReader("command=%2Fdeploy&team_id=T1")))
+
+	w := httptest.NewRecorder()
+	statsHandler(w, httptest.NewRequest(http.MethodGet, "/stats", nil))
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected JSON content type, got %q", ct)
+	}
+	var stats Stats
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+	if stats.Received-received != 3 || stats.Published-published != 2 || stats.PublishFailures-failures != 1 {
+		t.Errorf("unexpected counters: %+v", stats)
+	}
+	if stats.Backend != "redis" || stats.Channel != "ops-commands" {
+		t.Errorf("expected running config in stats, got %+v", stats)
+	}
+}
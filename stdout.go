@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+)
+
+// stdoutPublisher writes each command's JSON envelope to stdout as one line
+// of newline-delimited JSON, for log collectors such as Fluent Bit or Vector.
+// Logs go to stderr, so stdout carries nothing but commands.
+type stdoutPublisher struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func newStdoutPublisher() *stdoutPublisher {
+	return &stdoutPublisher{out: os.Stdout}
+}
+
+// Publish writes the payload and its newline in a single write, so lines from
+// concurrent publishes never interleave
+func (p *stdoutPublisher) Publish(ctx context.Context, key string, payload []byte) error {
+	line := make([]byte, 0, len(payload)+1)
+	line = append(append(line, payload...), '\n')
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err := p.out.Write(line)
+	return err
+}
+
+func (p *stdoutPublisher) Close() error {
+	return nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestStdoutPublisher_WritesOneLinePerCommand(t *testing.T) {
+	var out bytes.Buffer
+	publisher := &stdoutPublisher{out: &out}
+
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Go(func() {
+			payload := fmt.Sprintf(`{"id":"%d","command":{"command":"/deploy"}}`, i)
+			if err := publisher.Publish(context.Background(), "T1", []byte(payload)); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	wg.Wait()
+
+	lines := 0
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		if !json.Valid(scanner.Bytes()) {
+			t.Errorf("line %d is not valid JSON: %s", lines+1, scanner.Bytes())
+		}
+		lines++
+	}
+	if lines != 50 {
+		t.Errorf("expected 50 lines, got %d", lines)
+	}
+}
+
+func TestNewBackendPublisher_Stdout(t *testing.T) {
+	publisher, err := newBackendPublisher(backendStdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := publisher.(*stdoutPublisher); !ok {
+		t.Errorf("expected a stdout publisher, got %T", publisher)
+	}
+}
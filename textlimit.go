@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// What to do with command text longer than MAX_TEXT_LENGTH, via TEXT_OVERFLOW
+const (
+	textOverflowReject   = "reject"
+	textOverflowTruncate = "truncate"
+)
+
+// maxTextLength is the MAX_TEXT_LENGTH limit on command text in characters;
+// 0 disables it. Slack limits the text itself, but direct POSTs can exceed it.
+var maxTextLength int
+
+// textOverflow is the TEXT_OVERFLOW policy for text over maxTextLength
+var textOverflow = textOverflowReject
+
+// parseTextOverflow converts a string to a text overflow policy, defaulting to reject
+func parseTextOverflow(value string) string {
+	switch strings.ToLower(value) {
+	case "", textOverflowReject:
+		return textOverflowReject
+	case textOverflowTruncate:
+		return textOverflowTruncate
+	default:
+		logWarn("Unknown text overflow policy %q, using %s", value, textOverflowReject)
+		return textOverflowReject
+	}
+}
+
+// textTooLong reports whether text exceeds MAX_TEXT_LENGTH, and its length in characters
+func textTooLong(text string) (int, bool) {
+	if maxTextLength <= 0 {
+		return 0, false
+	}
+	n := utf8.RuneCountInString(text)
+	return n, n > maxTextLength
+}
+
+// truncateText shortens text to at most limit characters, never splitting a
+// multi-byte character
+func truncateText(text string, limit int) string {
+	for i := range text {
+		if limit == 0 {
+			return text[:i]
+		}
+		limit--
+	}
+	return text
+}
+
+// textTooLongMessage tells the user how far over the limit their command was
+func textTooLongMessage(n int) string {
+	return fmt.Sprintf("The command text is too long (%d characters, the limit is %d). Please shorten it and try again.", n, maxTextLength)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		text     string
+		limit    int
+		expected string
+	}{
+		{"hello", 10, "hello"},
+		{"hello", 5, "hello"},
+		{"hello world", 5, "hello"},
+		{"héllo wörld", 7, "héllo w"},
+		{"🎉🎉🎉", 2, "🎉🎉"},
+		{"abc", 0, ""},
+	}
+	for _, tt := range tests {
+		if got := truncateText(tt.text, tt.limit); got != tt.expected {
+			t.Errorf("truncateText(%q, %d) = %q, want %q", tt.text, tt.limit, got, tt.expected)
+		}
+	}
+}
+
+func TestSlackCommandHandler_TextLimit(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	origLength, origOverflow := maxTextLength, textOverflow
+	t.Cleanup(func() { maxTextLength, textOverflow = origLength, origOverflow })
+	setSigningSecrets(nil)
+	publishQueue = nil
+	maxTextLength = 5
+
+	send := func(fake *fakePublisher) *httptest.ResponseRecorder {
+		activePublisher = fake
+		w := httptest.NewRecorder()
+		slackCommandHandler(w, httptest.NewRequest(http.MethodPost, "/command", strings.NewReader("command=%2Fdeploy&team_id=T1&text=api+production")))
+		return w
+	}
+
+	textOverflow = textOverflowReject
+	rejected := &fakePublisher{}
+	w := send(rejected)
+	if len(rejected.payloads) != 0 {
+		t.Errorf("expected a rejected command not to be published, got %d publishes", len(rejected.payloads))
+	}
+	if !strings.Contains(w.Body.String(), "14 characters, the limit is 5") {
+		t.Errorf("expected a message with the length and limit, got %s", w.Body.String())
+	}
+
+	textOverflow = textOverflowTruncate
+	truncated := &fakePublisher{}
+	send(truncated)
+	if len(truncated.payloads) != 1 {
+		t.Fatalf("expected the truncated command to be published, got %d publishes", len(truncated.payloads))
+	}
+	var envelope struct {
+		Command       SlackCommand `json:"command"`
+		Args          []string     `json:"args"`
+		TextTruncated bool         `json:"text_truncated"`
+	}
+	if err := json.Unmarshal(truncated.payloads[0], &envelope); err != nil {
+		t.Fatal(err)
+	}
+	if envelope.Command.Text != "api p" || !envelope.TextTruncated || len(envelope.Args) != 2 {
+		t.Errorf("unexpected truncated envelope %+v", envelope)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies the relay's instrumentation in exported spans
+const tracerName = "github.com/its-the-vibe/SlackCommandRelay"
+
+// tracer creates the relay's spans. Until setupTracing installs a provider it
+// is a no-op, so tracing costs nothing when OTEL_ENABLED is unset.
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// tracingShutdown flushes and stops the tracer provider; nil when tracing is disabled
+var tracingShutdown func(context.Context) error
+
+// setupTracing installs an OTLP/HTTP tracer provider configured by the standard
+// OTEL_EXPORTER_OTLP_* and OTEL_SERVICE_NAME environment variables
+func setupTracing(ctx context.Context) error {
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return err
+	}
+	res, err := resource.Merge(
+		resource.NewSchemaless(attribute.String("service.name", "slack-command-relay")),
+		resource.Environment(),
+	)
+	if err != nil {
+		return err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	tracingShutdown = provider.Shutdown
+	return nil
+}
+
+// traceCarrier returns the trace context of ctx in its W3C propagation form,
+// e.g. {"traceparent": "00-..."}, or nil when there is no active trace
+func traceCarrier(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	return carrier
+}
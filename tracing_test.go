@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSlackCommandHandler_Tracing(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	setSigningSecrets(nil)
+	publisher := &fakePublisher{}
+	activePublisher = publisher
+
+	recorder := tracetest.NewSpanRecorder()
+	origProvider, origPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(origProvider)
+		otel.SetTextMapPropagator(origPropagator)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/command", strings.NewReader("command=%2Fdeploy&team_id=T1&user_id=U1"))
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	slackCommandHandler(w, req)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected handler and publish spans, got %d", len(spans))
+	}
+	publishSpan, handlerSpan := spans[0], spans[1]
+	if handlerSpan.Name() != "slack.command" || publishSpan.Name() != "publish" {
+		t.Fatalf("unexpected span names %q, %q", handlerSpan.Name(), publishSpan.Name())
+	}
+	if got := handlerSpan.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("expected inbound trace to be continued, got trace %s", got)
+	}
+	if publishSpan.Parent().SpanID() != handlerSpan.SpanContext().SpanID() {
+		t.Error("expected publish span to be a child of the handler span")
+	}
+	attrs := map[attribute.Key]string{}
+	for _, kv := range handlerSpan.Attributes() {
+		attrs[kv.Key] = kv.Value.Emit()
+	}
+	if attrs["slack.command"] != "/deploy" || attrs["slack.team_id"] != "T1" || attrs["slack.user_id"] != "U1" {
+		t.Errorf("unexpected handler span attributes: %v", attrs)
+	}
+
+	var envelope struct {
+		Trace map[string]string `json:"trace"`
+	}
+	if err := json.Unmarshal(publisher.payloads[0], &envelope); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(envelope.Trace["traceparent"], handlerSpan.SpanContext().SpanID().String()) {
+		t.Errorf("expected payload to carry the handler span's traceparent, got %v", envelope.Trace)
+	}
+}
+
+func TestTraceCarrier_NoActiveTrace(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/command", nil)
+	if carrier := traceCarrier(req.Context()); carrier != nil {
+		t.Errorf("expected no trace context, got %v", carrier)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+)
+
+// trustedNetworks are the TRUSTED_IPS networks whose requests skip Slack
+// signature verification. It is meant for local testing only, e.g. through an
+// ngrok tunnel, and is empty by default.
+var trustedNetworks []netip.Prefix
+
+// parseTrustedIPs parses TRUSTED_IPS, a comma-separated list of CIDRs or bare
+// IP addresses such as "127.0.0.1,10.0.0.0/8"
+func parseTrustedIPs(value string) ([]netip.Prefix, error) {
+	var networks []netip.Prefix
+	for _, item := range splitList(value) {
+		if addr, err := netip.ParseAddr(item); err == nil {
+			networks = append(networks, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP or CIDR %q", item)
+		}
+		networks = append(networks, prefix.Masked())
+	}
+	return networks, nil
+}
+
+// isTrustedIP reports whether remoteAddr, an http.Request RemoteAddr, is in
+// trustedNetworks. Forwarding headers are ignored since any client can set them.
+func isTrustedIP(remoteAddr string) bool {
+	if len(trustedNetworks) == 0 {
+		return false
+	}
+	addr, ok := remoteIP(remoteAddr)
+	if !ok {
+		return false
+	}
+	for _, network := range trustedNetworks {
+		if network.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// isLoopback reports whether remoteAddr, an http.Request RemoteAddr, is a
+// loopback address, i.e. the request came from the same host
+func isLoopback(remoteAddr string) bool {
+	addr, ok := remoteIP(remoteAddr)
+	return ok && addr.IsLoopback()
+}
+
+// remoteIP parses the IP address of an http.Request RemoteAddr
+func remoteIP(remoteAddr string) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
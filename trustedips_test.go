@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTrustedIPs(t *testing.T) {
+	networks, err := parseTrustedIPs("127.0.0.1, 10.1.2.3/8, ::1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"127.0.0.1/32", "10.0.0.0/8", "::1/128"}
+	if len(networks) != len(expected) {
+		t.Fatalf("expected %d networks, got %v", len(expected), networks)
+	}
+	for i, network := range networks {
+		if network.String() != expected[i] {
+			t.Errorf("network %d = %s, want %s", i, network, expected[i])
+		}
+	}
+
+	if _, err := parseTrustedIPs("localhost"); err == nil {
+		t.Error("expected an error for a hostname")
+	}
+}
+
+func TestIsTrustedIP(t *testing.T) {
+	orig := trustedNetworks
+	t.Cleanup(func() { trustedNetworks = orig })
+
+	trustedNetworks = nil
+	if isTrustedIP("127.0.0.1:1234") {
+		t.Error("expected no IP to be trusted by default")
+	}
+
+	trustedNetworks, _ = parseTrustedIPs("127.0.0.1,10.0.0.0/8")
+	tests := []struct {
+		remoteAddr string
+		expected   bool
+	}{
+		{"127.0.0.1:1234", true},
+		{"10.20.30.40:80", true},
+		{"[::ffff:10.0.0.1]:80", true},
+		{"192.168.1.1:1234", false},
+		{"not-an-address", false},
+	}
+	for _, tt := range tests {
+		if got := isTrustedIP(tt.remoteAddr); got != tt.expected {
+			t.Errorf("isTrustedIP(%q) = %t, want %t", tt.remoteAddr, got, tt.expected)
+		}
+	}
+}
+
+func TestSlackCommandHandler_TrustedIPSkipsSignature(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	orig := trustedNetworks
+	t.Cleanup(func() { trustedNetworks = orig })
+	setSigningSecrets([][]byte{[]byte("real-secret")})
+	setRedisClient(nil)
+	trustedNetworks, _ = parseTrustedIPs("10.0.0.0/8")
+
+	tests := []struct {
+		remoteAddr string
+		expected   int
+	}{
+		{"10.0.0.5:4000", http.StatusOK},
+		{"192.0.2.1:4000", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/command", strings.NewReader("command=%2Ftest&team_id=T1"))
+		req.RemoteAddr = tt.remoteAddr
+		req.Header.Set("X-Slack-Request-Timestamp", fmt.Sprintf("%d", time.Now().Unix()))
+		req.Header.Set("X-Slack-Signature", "v0=badhash")
+		// A spoofed forwarding header must not grant trust
+		req.Header.Set("X-Forwarded-For", "10.0.0.5")
+		w := httptest.NewRecorder()
+		slackCommandHandler(w, req)
+
+		if w.Code != tt.expected {
+			t.Errorf("%s: expected %d, got %d", tt.remoteAddr, tt.expected, w.Code)
+		}
+	}
+}
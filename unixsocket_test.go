@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// socketPath returns a socket path short enough for the sun_path limit
+func socketPath(t *testing.T) string {
+	dir, err := os.MkdirTemp("", "relay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "relay.sock")
+}
+
+func TestListenUnix(t *testing.T) {
+	path := socketPath(t)
+	listener, err := listenUnix(path)
+	if err != nil {
+		t.Fatalf("listenUnix: %v", err)
+	}
+	if _, err := listenUnix(path); err == nil {
+		t.Error("expected an error for a socket in use")
+	}
+
+	listener.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected the socket file to be removed on close, got %v", err)
+	}
+}
+
+func TestListenUnix_RemovesStaleSocket(t *testing.T) {
+	path := socketPath(t)
+	// Simulate a process that exited without removing its socket
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	listener, err := listenUnix(path)
+	if err != nil {
+		t.Fatalf("expected the stale socket to be replaced, got %v", err)
+	}
+	listener.Close()
+}
+
+func TestListenUnix_RefusesRegularFile(t *testing.T) {
+	path := socketPath(t)
+	if err := os.WriteFile(path, []byte("data"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := listenUnix(path); err == nil {
+		t.Error("expected an error for a path that is not a socket")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("expected the file to be left alone, got %v", err)
+	}
+}
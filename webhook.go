@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// defaultWebhookTimeout bounds each webhook POST when WEBHOOK_TIMEOUT is unset
+const defaultWebhookTimeout = 5 * time.Second
+
+// webhookURL is the HTTP endpoint commands are forwarded to; empty disables forwarding
+var webhookURL string
+
+// webhookRetry queues failed webhook deliveries for retry when true
+var webhookRetry bool
+
+var webhookClient = &http.Client{Timeout: defaultWebhookTimeout}
+
+// validateWebhookURL checks that a WEBHOOK_URL is an absolute http(s) URL
+func validateWebhookURL(raw string) (*url.URL, error) {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("scheme must be http or https, got %q", parsed.Scheme)
+	}
+	if parsed.Host == "" {
+		return nil, fmt.Errorf("missing host")
+	}
+	return parsed, nil
+}
+
+// webhookPublisher forwards commands to WEBHOOK_URL
+type webhookPublisher struct{}
+
+func (webhookPublisher) Publish(ctx context.Context, key string, payload []byte) error {
+	return publishToWebhook(ctx, publishMetaFrom(ctx).requestID, payload)
+}
+
+func (webhookPublisher) Close() error {
+	return nil
+}
+
+// publishToWebhook POSTs a JSON payload to the configured webhook. Non-2xx
+// responses are returned as errors.
+func publishToWebhook(ctx context.Context, requestID string, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if requestID != "" {
+		req.Header.Set(requestIDHeader, requestID)
+	}
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Drain the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
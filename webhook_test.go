@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPublishToWebhook(t *testing.T) {
+	saveAndRestoreGlobals(t)
+
+	var gotBody, gotRequestID, gotContentType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		gotRequestID = r.Header.Get("X-Request-ID")
+		gotContentType = r.Header.Get("Content-Type")
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	webhookURL = server.URL
+
+	if err := publishToWebhook(context.Background(), "req-1", []byte(`{"id":"1"}`)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotBody != `{"id":"1"}` {
+		t.Errorf("expected payload to be posted, got %q", gotBody)
+	}
+	if gotRequestID != "req-1" {
+		t.Errorf("expected X-Request-ID req-1, got %q", gotRequestID)
+	}
+	if gotContentType != "application/json" {
+		t.Errorf("expected JSON content type, got %q", gotContentType)
+	}
+}
+
+func TestPublishToWebhook_Non2xx(t *testing.T) {
+	saveAndRestoreGlobals(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusBadGateway)
+	}))
+	defer server.Close()
+	webhookURL = server.URL
+
+	if err := publishToWebhook(context.Background(), "", []byte("{}")); err == nil {
+		t.Error("expected an error for a non-2xx response")
+	}
+}
+
+func TestSlackCommandHandler_ForwardsToWebhook(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	setSigningSecrets(nil)
+	setRedisClient(nil)
+
+	received := make(chan map[string]interface{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var envelope map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&envelope); err != nil {
+			t.Errorf("expected a JSON envelope: %v", err)
+		}
+		received <- envelope
+	}))
+	defer server.Close()
+	webhookURL = server.URL
+	activePublisher = webhookPublisher{}
+
+	req := httptest.NewRequest(http.MethodPost, "/command", strings.NewReader("command=%2Fdeploy&team_id=T1"))
+	req.Header.Set("X-Request-ID", "trace-1")
+	w := httptest.NewRecorder()
+	slackCommandHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	select {
+	case envelope := <-received:
+		command, _ := envelope["command"].(map[string]interface{})
+		if command["command"] != "/deploy" || envelope["request_id"] != "trace-1" {
+			t.Errorf("unexpected forwarded envelope: %v", envelope)
+		}
+	default:
+		t.Error("expected command to be forwarded to the webhook")
+	}
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	tests := []struct {
+		input   string
+		wantErr bool
+	}{
+		{"https://example.com/hook", false},
+		{"http://localhost:9000", false},
+		{"ftp://example.com", true},
+		{"example.com/hook", true},
+		{"https://", true},
+	}
+	for _, tt := range tests {
+		if _, err := validateWebhookURL(tt.input); (err != nil) != tt.wantErr {
+			t.Errorf("validateWebhookURL(%q) error = %v, wantErr %t", tt.input, err, tt.wantErr)
+		}
+	}
+}
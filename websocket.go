@@ -0,0 +1,203 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Tuning for the /ws command feed
+const (
+	webSocketPath = "/ws"
+
+	// webSocketSendBuffer is how many commands a client may fall behind by
+	// before commands are dropped for it
+	webSocketSendBuffer = 64
+	// webSocketBroadcastBuffer queues commands for the hub so the handler never waits on it
+	webSocketBroadcastBuffer = 256
+
+	webSocketWriteWait  = 10 * time.Second
+	webSocketPongWait   = 60 * time.Second
+	webSocketPingPeriod = webSocketPongWait * 9 / 10
+)
+
+// enableWebSocket serves the live command feed at webSocketPath, from ENABLE_WEBSOCKET
+var enableWebSocket bool
+
+// commandFeed fans received commands out to the /ws clients
+var commandFeed = newWebSocketHub()
+
+var webSocketDroppedCommands = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "slack_websocket_dropped_commands_total",
+	Help: "Total number of commands dropped for WebSocket clients that fell behind.",
+})
+
+// Clients authenticate with the admin token header, which a cross-site page
+// can't set, so any origin may connect
+var webSocketUpgrader = websocket.Upgrader{
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+// webSocketClient is a connected /ws client and its bounded send buffer
+type webSocketClient struct {
+	conn *websocket.Conn
+	send chan []byte
+}
+
+// webSocketHub owns the set of clients. Registration, removal and broadcasts
+// all go through its run loop, so the set needs no lock.
+type webSocketHub struct {
+	register   chan *webSocketClient
+	unregister chan *webSocketClient
+	broadcast  chan []byte
+	quit       chan struct{}
+	done       chan struct{}
+	clients    map[*webSocketClient]bool
+}
+
+func newWebSocketHub() *webSocketHub {
+	return &webSocketHub{
+		register:   make(chan *webSocketClient),
+		unregister: make(chan *webSocketClient),
+		broadcast:  make(chan []byte, webSocketBroadcastBuffer),
+		quit:       make(chan struct{}),
+		done:       make(chan struct{}),
+		clients:    make(map[*webSocketClient]bool),
+	}
+}
+
+// run serves registrations and broadcasts until stop is called
+func (h *webSocketHub) run() {
+	defer close(h.done)
+	for {
+		select {
+		case client := <-h.register:
+			h.clients[client] = true
+			logInfo("WebSocket client connected from %s (%d connected)", client.conn.RemoteAddr(), len(h.clients))
+		case client := <-h.unregister:
+			if h.clients[client] {
+				delete(h.clients, client)
+				close(client.send)
+				logInfo("WebSocket client disconnected from %s (%d connected)", client.conn.RemoteAddr(), len(h.clients))
+			}
+		case payload := <-h.broadcast:
+			for client := range h.clients {
+				select {
+				case client.send <- payload:
+				default:
+					webSocketDroppedCommands.Inc()
+					logWarn("WebSocket client %s fell behind, dropped a command", client.conn.RemoteAddr())
+				}
+			}
+		case <-h.quit:
+			// Closing the send buffers makes each writer send a close frame
+			for client := range h.clients {
+				delete(h.clients, client)
+				close(client.send)
+			}
+			return
+		}
+	}
+}
+
+// publish queues a command's JSON for the clients without blocking the caller
+func (h *webSocketHub) publish(payload []byte) {
+	select {
+	case h.broadcast <- payload:
+	default:
+		webSocketDroppedCommands.Inc()
+		logWarn("WebSocket broadcast queue full, dropped a command")
+	}
+}
+
+// stop disconnects every client and ends the run loop
+func (h *webSocketHub) stop() {
+	close(h.quit)
+	<-h.done
+}
+
+// feedCommand sends a command's JSON to the /ws clients when ENABLE_WEBSOCKET is set
+func feedCommand(payload []byte) {
+	if !enableWebSocket {
+		return
+	}
+	commandFeed.publish(payload)
+}
+
+// webSocketHandler upgrades operators holding the admin token to the live
+// command feed
+func webSocketHandler(w http.ResponseWriter, r *http.Request) {
+	if !verifyAdminToken(r) {
+		logWarn("Rejected %s connection with an invalid admin token from %s", webSocketPath, r.RemoteAddr)
+		http.Error(w, "Invalid admin token", http.StatusUnauthorized)
+		return
+	}
+	conn, err := webSocketUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied with an error
+		logWarn("WebSocket upgrade from %s failed: %v", r.RemoteAddr, err)
+		return
+	}
+	client := &webSocketClient{conn: conn, send: make(chan []byte, webSocketSendBuffer)}
+	select {
+	case commandFeed.register <- client:
+	case <-commandFeed.done:
+		conn.Close()
+		return
+	}
+	go client.writePump()
+	go client.readPump(commandFeed)
+}
+
+// readPump discards client messages and answers pings, unregistering the
+// client once the connection fails or closes
+func (c *webSocketClient) readPump(hub *webSocketHub) {
+	defer func() {
+		select {
+		case hub.unregister <- c:
+		case <-hub.done:
+		}
+		c.conn.Close()
+	}()
+	c.conn.SetReadLimit(512)
+	c.conn.SetReadDeadline(time.Now().Add(webSocketPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(webSocketPongWait))
+	})
+	for {
+		if _, _, err := c.conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// writePump writes queued commands and keepalive pings to the client, and a
+// close frame once the send buffer is closed
+func (c *webSocketClient) writePump() {
+	ticker := time.NewTicker(webSocketPingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+	for {
+		select {
+		case payload, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(webSocketWriteWait))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(webSocketWriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// useWebSocketHub runs a fresh hub as the command feed for the test
+func useWebSocketHub(t *testing.T) *webSocketHub {
+	t.Helper()
+	origEnabled, origFeed, origToken := enableWebSocket, commandFeed, adminToken
+	t.Cleanup(func() { enableWebSocket, commandFeed, adminToken = origEnabled, origFeed, origToken })
+	enableWebSocket = true
+	adminToken = []byte("admin-secret")
+	commandFeed = newWebSocketHub()
+	go commandFeed.run()
+	return commandFeed
+}
+
+// dialCommandFeed connects to a test server for /ws, returning once the
+// client is registered with the hub
+func dialCommandFeed(t *testing.T) *websocket.Conn {
+	t.Helper()
+	registered := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		webSocketHandler(w, r)
+		close(registered)
+	}))
+	t.Cleanup(server.Close)
+
+	header := http.Header{adminTokenHeader: []string{"admin-secret"}}
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+webSocketPath, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	<-registered
+	return conn
+}
+
+func TestWebSocketHandler_RequiresAdminToken(t *testing.T) {
+	useWebSocketHub(t)
+	for _, token := range []string{"", "wrong"} {
+		req := httptest.NewRequest(http.MethodGet, webSocketPath, nil)
+		if token != "" {
+			req.Header.Set(adminTokenHeader, token)
+		}
+		w := httptest.NewRecorder()
+		webSocketHandler(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("token %q: expected 401, got %d", token, w.Code)
+		}
+	}
+}
+
+func TestWebSocketFeed_StreamsReceivedCommands(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	setSigningSecrets(nil)
+	publishQueue = nil
+	fake := &fakePublisher{}
+	activePublisher = fake
+	hub := useWebSocketHub(t)
+	conn := dialCommandFeed(t)
+
+	body := "command=%2Fdeploy&team_id=T1&text=api+production"
+	w := httptest.NewRecorder()
+	slackCommandHandler(w, httptest.NewRequest(http.MethodPost, "/command", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	kind, message, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kind != websocket.TextMessage || string(message) != string(fake.payloads[0]) {
+		t.Errorf("expected the published JSON, got %s", message)
+	}
+
+	// Stopping the hub at shutdown closes the connection cleanly
+	hub.stop()
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Errorf("expected a close frame, got %v", err)
+	}
+}
+
+func TestWebSocketHub_DropsForSlowClient(t *testing.T) {
+	hub := useWebSocketHub(t)
+	conn := dialCommandFeed(t)
+	// A client without a writer stands in for one that stopped reading
+	slow := &webSocketClient{conn: conn, send: make(chan []byte, 1)}
+	hub.register <- slow
+	before := testutil.ToFloat64(webSocketDroppedCommands)
+
+	for range 3 {
+		hub.publish([]byte(`{}`))
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for testutil.ToFloat64(webSocketDroppedCommands)-before < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := testutil.ToFloat64(webSocketDroppedCommands) - before; got != 2 {
+		t.Errorf("expected 2 dropped commands, got %v", got)
+	}
+	if len(slow.send) != 1 {
+		t.Errorf("expected the slow client to keep its buffered command, got %d", len(slow.send))
+	}
+
+	// The other client keeps receiving every command
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for range 3 {
+		if _, message, err := conn.ReadMessage(); err != nil || string(message) != `{}` {
+			t.Fatalf("expected every command, got %q, %v", message, err)
+		}
+	}
+}
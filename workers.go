@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// defaultPublishWorkers is the number of publish goroutines when PUBLISH_WORKERS is unset
+	defaultPublishWorkers = 4
+
+	// defaultPublishQueueSize is the number of commands buffered for the workers
+	// when PUBLISH_QUEUE_SIZE is unset
+	defaultPublishQueueSize = 1000
+
+	// defaultDrainTimeout bounds how long shutdown waits for the workers to
+	// publish queued commands when DRAIN_TIMEOUT is unset
+	defaultDrainTimeout = 10 * time.Second
+)
+
+// publishJob is a command waiting to be published
+type publishJob struct {
+	key       string
+	command   SlackCommand
+	requestID string
+	payload   []byte
+	// channel overrides the command's Redis channel when set
+	channel string
+	// span is the handler's span, parent of the publish span
+	span trace.SpanContext
+	// dedupeKey marks the delivery as seen and is removed if the publish fails
+	dedupeKey string
+}
+
+// publishPool decouples Slack acknowledgements from publishing: the handler
+// submits jobs to a bounded channel and a fixed set of workers publishes them.
+type publishPool struct {
+	jobs chan publishJob
+	wg   sync.WaitGroup
+
+	// ctx is cancelled when draining times out, stopping the workers
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// abandoned holds jobs a worker received after ctx was cancelled; closed
+	// is set by drain so late submits don't send on the closed jobs channel
+	mu        sync.Mutex
+	abandoned []publishJob
+	closed    bool
+}
+
+// publishQueue is the async worker pool; nil means commands are published
+// synchronously before Slack is acknowledged
+var publishQueue *publishPool
+
+// drainTimeout is how long shutdown waits for the workers to empty publishQueue
+var drainTimeout = defaultDrainTimeout
+
+func newPublishPool(workers, size int) *publishPool {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &publishPool{jobs: make(chan publishJob, size), ctx: ctx, cancel: cancel}
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for job := range p.jobs {
+				if p.ctx.Err() != nil {
+					p.mu.Lock()
+					p.abandoned = append(p.abandoned, job)
+					p.mu.Unlock()
+					return
+				}
+				publishCommand(p.ctx, job)
+			}
+		}()
+	}
+	return p
+}
+
+// submit queues a job without blocking, returning false if the queue is full
+// or already draining. A handler that outlived REQUEST_TIMEOUT can still reach
+// it during shutdown.
+func (p *publishPool) submit(job publishJob) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return false
+	}
+	select {
+	case p.jobs <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+// drain stops accepting jobs and waits for the workers to publish the queued
+// ones until ctx is done. The workers are then stopped, cancelling in-flight
+// publishes, and the jobs they never started are returned.
+func (p *publishPool) drain(ctx context.Context) []publishJob {
+	p.mu.Lock()
+	p.closed = true
+	close(p.jobs)
+	p.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+	}
+
+	p.cancel()
+	<-done
+	p.mu.Lock()
+	remaining := p.abandoned
+	p.mu.Unlock()
+	for job := range p.jobs {
+		remaining = append(remaining, job)
+	}
+	return remaining
+}
+
+// deadLetterJob writes a job that was never published to the dead-letter sink
+func deadLetterJob(job publishJob, reason string) {
+	deadLetterItem(retryItem{
+		backend:   strings.Join(backends, ","),
+		key:       job.key,
+		command:   job.command,
+		payload:   job.payload,
+		requestID: job.requestID,
+		channel:   job.channel,
+	}, reason)
+}
+
+// publishCommand publishes a job to the active publisher. Failures are logged,
+// queued for retry on the failed backends and returned.
+func publishCommand(ctx context.Context, job publishJob) error {
+	// Workers publish after the handler has returned, so parent the span explicitly
+	ctx, span := tracer().Start(trace.ContextWithSpanContext(ctx, job.span), "publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("messaging.system", strings.Join(backends, ",")), attribute.String("slack.command", job.command.Command)))
+	defer span.End()
+
+	ctx = withChannelOverride(withPublishMeta(ctx, job.command, job.requestID), job.channel)
+	err := activePublisher.Publish(ctx, job.key, job.payload)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "publish failed")
+		logRequest(ERROR, job.requestID, "Error publishing command %s: %v", job.command.Command, err)
+		publishFailures.Inc()
+		statsPublishFailures.Add(1)
+		if job.dedupeKey != "" {
+			forgetDelivery(ctx, job.dedupeKey)
+		}
+		if publishRetryQueue != nil {
+			for _, failure := range failedPublishers(err, activePublisher) {
+				if retryable(failure.Publisher) {
+					publishRetryQueue.enqueue(retryItem{target: failure.Publisher, backend: failure.Backend, key: job.key, command: job.command, channel: job.channel, payload: job.payload, requestID: job.requestID})
+				}
+			}
+		}
+		return err
+	}
+	statsPublished.Add(1)
+	logRequest(INFO, job.requestID, "Published command %s to %s", job.command.Command, strings.Join(backends, ","))
+	return nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// gatedPublisher blocks every publish until release is closed
+type gatedPublisher struct {
+	release   chan struct{}
+	published chan string
+}
+
+func (g *gatedPublisher) Publish(ctx context.Context, key string, payload []byte) error {
+	<-g.release
+	g.published <- publishMetaFrom(ctx).command.Command
+	return nil
+}
+
+func (g *gatedPublisher) Close() error {
+	return nil
+}
+
+func TestSlackCommandHandler_AcksBeforeAsyncPublish(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	setSigningSecrets(nil)
+	publisher := &gatedPublisher{release: make(chan struct{}), published: make(chan string, 1)}
+	activePublisher = publisher
+	pool := newPublishPool(1, 10)
+	publishQueue = pool
+	t.Cleanup(func() { pool.drain(context.Background()) })
+
+	req := httptest.NewRequest(http.MethodPost, "/command", strings.NewReader("command=%2Fdeploy&team_id=T1"))
+	w := httptest.NewRecorder()
+	slackCommandHandler(w, req)
+
+	// The handler returned while the publish is still blocked
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	close(publisher.release)
+	select {
+	case command := <-publisher.published:
+		if command != "/deploy" {
+			t.Errorf("expected /deploy to be published, got %s", command)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the worker to publish the command")
+	}
+}
+
+func TestSlackCommandHandler_AcknowledgementFollowsQueueing(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	setSigningSecrets(nil)
+	fake := &fakePublisher{}
+	activePublisher = fake
+	asyncResponseTemplate = defaultAsyncResponse
+	asyncAcknowledgement = true
+	// Without workers the single queued job fills the queue
+	pool := newPublishPool(0, 1)
+	publishQueue = pool
+	t.Cleanup(func() { pool.drain(context.Background()) })
+
+	for _, expected := range []string{"⏳ `/deploy` is being processed", "Slash command `/deploy` received 🎉"} {
+		w := httptest.NewRecorder()
+		slackCommandHandler(w, httptest.NewRequest(http.MethodPost, "/command", strings.NewReader("command=%2Fdeploy&team_id=T1")))
+		if !strings.Contains(w.Body.String(), expected) {
+			t.Errorf("expected %q, got %s", expected, w.Body.String())
+		}
+	}
+	if len(fake.payloads) != 1 {
+		t.Errorf("expected the command that didn't fit in the queue to be published synchronously, got %d publishes", len(fake.payloads))
+	}
+}
+
+func TestPublishPool_SubmitAfterDrain(t *testing.T) {
+	pool := newPublishPool(1, 10)
+	pool.drain(context.Background())
+
+	// A late handler must fall back to publishing itself rather than panic
+	if pool.submit(publishJob{requestID: "late"}) {
+		t.Error("expected submit to be refused after drain")
+	}
+}
+
+func TestShutdown_DeadLettersUndrainedJobs(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	origSink, origRetry, origTimeout := deadLetter, publishRetryQueue, drainTimeout
+	t.Cleanup(func() { deadLetter, publishRetryQueue, drainTimeout = origSink, origRetry, origTimeout })
+	path := filepath.Join(t.TempDir(), "dlq.ndjson")
+	deadLetter = parseDeadLetterSink(path)
+	publishRetryQueue = nil
+	drainTimeout = 50 * time.Millisecond
+
+	// The single worker blocks on the first job until the drain times out
+	publisher := &blockingPublisher{cancelled: make(chan error, 1)}
+	activePublisher = publisher
+	publishQueue = newPublishPool(1, 10)
+	for _, command := range []string{"/one", "/two", "/three"} {
+		publishQueue.submit(publishJob{command: SlackCommand{Command: command}, payload: []byte(`{}`)})
+	}
+
+	shutdown(nil, time.Second)
+
+	if err := <-publisher.cancelled; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the in-flight publish to be cancelled, got %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected dead-letter file: %v", err)
+	}
+	for _, command := range []string{"/two", "/three"} {
+		if !strings.Contains(string(data), `"command":"`+command+`"`) {
+			t.Errorf("expected %s in dead-letter file, got %s", command, data)
+		}
+	}
+	if !strings.Contains(string(data), "undrained at shutdown") {
+		t.Errorf("expected drain reason in dead-letter file, got %s", data)
+	}
+}
+
+func TestPublishPool_SubmitWhenFull(t *testing.T) {
+	// No workers, so nothing drains the queue
+	pool := newPublishPool(0, 1)
+	if !pool.submit(publishJob{}) {
+		t.Fatal("expected first job to be queued")
+	}
+	if pool.submit(publishJob{}) {
+		t.Error("expected submit to fail when the queue is full")
+	}
+}
+
+func TestSlackCommandHandler_PublishesSynchronouslyWhenQueueFull(t *testing.T) {
+	saveAndRestoreGlobals(t)
+	setSigningSecrets(nil)
+	publisher := &fakePublisher{}
+	activePublisher = publisher
+	publishQueue = newPublishPool(0, 1)
+	publishQueue.submit(publishJob{})
+
+	req := httptest.NewRequest(http.MethodPost, "/command", strings.NewReader("command=%2Fdeploy&team_id=T1"))
+	w := httptest.NewRecorder()
+	slackCommandHandler(w, req)
+
+	if len(publisher.payloads) != 1 {
+		t.Errorf("expected an inline publish when the queue is full, got %d", len(publisher.payloads))
+	}
+}